@@ -3,20 +3,24 @@ package adrepo
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"homework8/internal/ads"
 	"homework8/internal/app"
+	"homework8/internal/search"
 	"homework8/internal/users"
 )
 
 var ErrNotFound = fmt.Errorf("not found")
 
 type StorageAd struct {
-	mx   *sync.RWMutex
-	data map[int64]*ads.Ad
+	mx    *sync.RWMutex
+	data  map[int64]*ads.Ad
+	index *search.Index
 }
 
 type StorageUser struct {
@@ -27,12 +31,15 @@ type StorageUser struct {
 type RepositoryApp struct {
 	storageAd   *StorageAd
 	storageUser *StorageUser
+	txMx        *sync.Mutex
+	adSeq       *atomic.Int64
+	userSeq     *atomic.Int64
 }
 
 func New() app.Repository {
-	storageAd := &StorageAd{mx: &sync.RWMutex{}, data: make(map[int64]*ads.Ad)}
+	storageAd := &StorageAd{mx: &sync.RWMutex{}, data: make(map[int64]*ads.Ad), index: search.NewIndex()}
 	storageUser := &StorageUser{mx: &sync.RWMutex{}, data: make(map[int64]*users.User)}
-	return &RepositoryApp{storageAd: storageAd, storageUser: storageUser}
+	return &RepositoryApp{storageAd: storageAd, storageUser: storageUser, txMx: &sync.Mutex{}, adSeq: &atomic.Int64{}, userSeq: &atomic.Int64{}}
 }
 
 func (rs *RepositoryApp) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, error) {
@@ -45,49 +52,83 @@ func (rs *RepositoryApp) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, er
 		return &ads.Ad{}, ErrNotFound
 	}
 
-	return ad, nil
+	res := *ad
+
+	return &res, nil
 
 }
 
-func (rs *RepositoryApp) StoreAd(ctx context.Context, ad *ads.Ad) {
+// StoreAd выдает ID из последовательности, которая, как и в postgres, не откатывается вместе с транзакцией
+func (rs *RepositoryApp) StoreAd(ctx context.Context, ad *ads.Ad) (int64, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
 	rs.storageAd.mx.Lock()
 	defer rs.storageAd.mx.Unlock()
 
-	rs.storageAd.data[ad.ID] = ad
+	adID := rs.adSeq.Add(1) - 1
 
-}
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
 
-func (rs *RepositoryApp) LenAd(ctx context.Context) int64 {
-	rs.storageAd.mx.RLock()
-	defer rs.storageAd.mx.RUnlock()
+		delete(rs.storageAd.data, adID)
+		rs.storageAd.index.Remove(adID)
+	})
+
+	stored := *ad
+	stored.ID = adID
+	rs.storageAd.data[adID] = &stored
+	rs.storageAd.index.Add(adID, stored.Title, stored.Text)
+
+	return adID, nil
 
-	return int64(len(rs.storageAd.data))
 }
 
-func (rs *RepositoryApp) UpdateADByID(ctx context.Context, adID int64, title string, text string) {
+func (rs *RepositoryApp) UpdateADByID(ctx context.Context, adID int64, title string, text string) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
 	rs.storageAd.mx.Lock()
 	defer rs.storageAd.mx.Unlock()
 
-	rs.storageAd.data[adID].Title = title
-	rs.storageAd.data[adID].Text = text
-	rs.storageAd.data[adID].UpdateDate = time.Now().UTC()
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.rememberAd(ctx, ad)
+
+	ad.Title = title
+	ad.Text = text
+	ad.UpdateDate = time.Now().UTC()
+	rs.storageAd.index.Add(adID, title, text)
+
+	return nil
 
 }
 
-func (rs *RepositoryApp) UpdateAdStatus(ctx context.Context, adID int64, status bool) {
+func (rs *RepositoryApp) UpdateAdStatus(ctx context.Context, adID int64, status bool) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
 	rs.storageAd.mx.Lock()
 	defer rs.storageAd.mx.Unlock()
 
-	rs.storageAd.data[adID].Published = status
-	rs.storageAd.data[adID].UpdateDate = time.Now().UTC()
+	ad, ok := rs.storageAd.data[adID]
 
-}
+	if !ok {
+		return ErrNotFound
+	}
 
-func (rs *RepositoryApp) LenUser(ctx context.Context) int64 {
-	rs.storageUser.mx.RLock()
-	defer rs.storageUser.mx.RUnlock()
+	rs.rememberAd(ctx, ad)
+
+	ad.Published = status
+	ad.UpdateDate = time.Now().UTC()
+
+	return nil
 
-	return int64(len(rs.storageUser.data))
 }
 
 func (rs *RepositoryApp) GetUserByID(ctx context.Context, userID int64) (*users.User, error) {
@@ -100,24 +141,61 @@ func (rs *RepositoryApp) GetUserByID(ctx context.Context, userID int64) (*users.
 		return &users.User{}, ErrNotFound
 	}
 
-	return user, nil
+	res := *user
+
+	return &res, nil
 
 }
 
-func (rs *RepositoryApp) StoreUser(ctx context.Context, user *users.User) {
+func (rs *RepositoryApp) StoreUser(ctx context.Context, user *users.User) (int64, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
 	rs.storageUser.mx.Lock()
 	defer rs.storageUser.mx.Unlock()
 
-	rs.storageUser.data[user.ID] = user
+	userID := rs.userSeq.Add(1) - 1
+
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		delete(rs.storageUser.data, userID)
+	})
+
+	stored := *user
+	stored.ID = userID
+	rs.storageUser.data[userID] = &stored
+
+	return userID, nil
 
 }
 
-func (rs *RepositoryApp) UpdateUserByID(ctx context.Context, userID int64, nickname string, email string) {
+func (rs *RepositoryApp) UpdateUserByID(ctx context.Context, userID int64, nickname string, email string) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
 	rs.storageUser.mx.Lock()
 	defer rs.storageUser.mx.Unlock()
 
-	rs.storageUser.data[userID].Nickname = nickname
-	rs.storageUser.data[userID].Email = email
+	user, ok := rs.storageUser.data[userID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	prev := *user
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		*user = prev
+	})
+
+	user.Nickname = nickname
+	user.Email = email
+
+	return nil
 
 }
 
@@ -126,8 +204,9 @@ func (rs *RepositoryApp) SearchAdByName(ctx context.Context, adName string) (*ad
 	defer rs.storageAd.mx.RUnlock()
 
 	for _, v := range rs.storageAd.data {
-		if strings.Contains(v.Title, adName) {
-			return v, nil
+		if v.DeletedAt.IsZero() && strings.Contains(v.Title, adName) {
+			res := *v
+			return &res, nil
 		}
 	}
 
@@ -135,26 +214,56 @@ func (rs *RepositoryApp) SearchAdByName(ctx context.Context, adName string) (*ad
 
 }
 
-func (rs *RepositoryApp) FilterAds(ctx context.Context, filter *app.Filter) ([]*ads.Ad, error) {
+func (rs *RepositoryApp) SearchAds(ctx context.Context, query string, limit int) ([]*ads.Ad, error) {
 	rs.storageAd.mx.RLock()
 	defer rs.storageAd.mx.RUnlock()
 
 	res := []*ads.Ad{}
 
-	for _, v := range rs.storageAd.data {
-		if !v.Published {
-			continue
+	for _, found := range rs.storageAd.index.Search(query) {
+		if len(res) == limit {
+			break
 		}
-		if filter.AuthorID != -1 && v.AuthorID != filter.AuthorID {
+
+		v := rs.storageAd.data[found.ID]
+		if !v.Published || !v.DeletedAt.IsZero() {
 			continue
 		}
-		if !filter.PublishedAfter.IsZero() && v.CreationDate.Before(filter.PublishedAfter) {
+
+		ad := *v
+		res = append(res, &ad)
+	}
+
+	if len(res) == 0 {
+		return res, ErrNotFound
+	}
+
+	return res, nil
+}
+
+func (rs *RepositoryApp) FilterAds(ctx context.Context, filter *app.Filter) ([]*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Ad{}
+
+	for _, v := range rs.storageAd.data {
+		if !filter.Match(v) {
 			continue
 		}
-		if !filter.PublishedBefore.IsZero() && v.CreationDate.After(filter.PublishedBefore) {
+		if !filter.AfterCursor(v) {
 			continue
 		}
-		res = append(res, v)
+		ad := *v
+		res = append(res, &ad)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return filter.Less(res[i], res[j])
+	})
+
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
 	}
 
 	if len(res) == 0 {
@@ -163,3 +272,103 @@ func (rs *RepositoryApp) FilterAds(ctx context.Context, filter *app.Filter) ([]*
 
 	return res, nil
 }
+
+func (rs *RepositoryApp) SetAdDeletedAt(ctx context.Context, adID int64, deletedAt time.Time) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.rememberAd(ctx, ad)
+
+	ad.DeletedAt = deletedAt
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) PurgeAds(ctx context.Context, before time.Time) (int, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	purged := 0
+
+	for id, v := range rs.storageAd.data {
+		if !v.DeletedAt.IsZero() && !v.DeletedAt.After(before) {
+			rs.deleteAd(ctx, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+
+}
+
+func (rs *RepositoryApp) DeleteAdsByAuthor(ctx context.Context, authorID int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	for id, v := range rs.storageAd.data {
+		if v.AuthorID == authorID {
+			rs.deleteAd(ctx, id)
+		}
+	}
+
+	return nil
+
+}
+
+// deleteAd удаляет объявление из хранилища и индекса, вызывается под storageAd.mx
+func (rs *RepositoryApp) deleteAd(ctx context.Context, adID int64) {
+	ad := rs.storageAd.data[adID]
+
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
+
+		rs.storageAd.data[adID] = ad
+		rs.storageAd.index.Add(adID, ad.Title, ad.Text)
+	})
+
+	delete(rs.storageAd.data, adID)
+	rs.storageAd.index.Remove(adID)
+}
+
+func (rs *RepositoryApp) DeleteUser(ctx context.Context, userID int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageUser.mx.Lock()
+	defer rs.storageUser.mx.Unlock()
+
+	user, ok := rs.storageUser.data[userID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		rs.storageUser.data[userID] = user
+	})
+
+	delete(rs.storageUser.data, userID)
+
+	return nil
+
+}
@@ -0,0 +1,79 @@
+package adrepo
+
+import (
+	"context"
+
+	"homework8/internal/ads"
+)
+
+type txKey struct{}
+
+// txLog хранит операции отката изменений, сделанных внутри транзакции
+type txLog struct {
+	undo []func()
+}
+
+func txFromContext(ctx context.Context) (*txLog, bool) {
+	tx, ok := ctx.Value(txKey{}).(*txLog)
+	return tx, ok
+}
+
+// WithinTransaction выполняет fn эксклюзивно относительно остальных изменений хранилища.
+// Если fn вернула ошибку или запаниковала, все изменения, сделанные через переданный в fn контекст, откатываются.
+// Вложенный вызов выполняется в рамках уже открытой транзакции.
+func (rs *RepositoryApp) WithinTransaction(ctx context.Context, fn func(context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	rs.txMx.Lock()
+	defer rs.txMx.Unlock()
+
+	tx := &txLog{}
+	committed := false
+
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(tx.undo) - 1; i >= 0; i-- {
+			tx.undo[i]()
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	committed = true
+
+	return nil
+}
+
+// writeLock не дает изменениям вне транзакции вклиниться в открытую транзакцию
+func (rs *RepositoryApp) writeLock(ctx context.Context) func() {
+	if _, ok := txFromContext(ctx); ok {
+		return func() {}
+	}
+
+	rs.txMx.Lock()
+	return rs.txMx.Unlock
+}
+
+func (rs *RepositoryApp) onRollback(ctx context.Context, undo func()) {
+	if tx, ok := txFromContext(ctx); ok {
+		tx.undo = append(tx.undo, undo)
+	}
+}
+
+// rememberAd запоминает текущее состояние объявления для отката, вызывается под storageAd.mx
+func (rs *RepositoryApp) rememberAd(ctx context.Context, ad *ads.Ad) {
+	prev := *ad
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
+
+		*ad = prev
+		rs.storageAd.index.Add(prev.ID, prev.Title, prev.Text)
+	})
+}
@@ -0,0 +1,356 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"homework8/internal/ads"
+	"homework8/internal/app"
+	"homework8/internal/search"
+	"homework8/internal/users"
+)
+
+var ErrNotFound = fmt.Errorf("not found")
+
+type RepositoryPG struct {
+	pool *pgxpool.Pool
+}
+
+func New(pool *pgxpool.Pool) app.Repository {
+	return &RepositoryPG{pool: pool}
+}
+
+const adColumns = `id, title, text, author_id, published, creation_date, update_date, deleted_at`
+
+func scanAd(row pgx.Row) (*ads.Ad, error) {
+	ad := &ads.Ad{}
+
+	var updateDate, deletedAt *time.Time
+
+	if err := row.Scan(&ad.ID, &ad.Title, &ad.Text, &ad.AuthorID, &ad.Published, &ad.CreationDate, &updateDate, &deletedAt); err != nil {
+		return &ads.Ad{}, err
+	}
+
+	ad.CreationDate = ad.CreationDate.UTC()
+
+	if updateDate != nil {
+		ad.UpdateDate = updateDate.UTC()
+	}
+
+	if deletedAt != nil {
+		ad.DeletedAt = deletedAt.UTC()
+	}
+
+	return ad, nil
+}
+
+// nullTime переводит нулевое время в NULL, чтобы не хранить в базе 0001-01-01
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+const getAdByIDQuery = `SELECT ` + adColumns + ` FROM ads WHERE id = $1`
+
+// GetAdByID внутри транзакции блокирует строку объявления до конца транзакции,
+// чтобы конкурентные изменения одного объявления выполнялись последовательно
+func (r *RepositoryPG) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, error) {
+	q := getAdByIDQuery
+	if _, ok := txFromContext(ctx); ok {
+		q += ` FOR UPDATE`
+	}
+
+	ad, err := scanAd(r.conn(ctx).QueryRow(ctx, q, adID))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &ads.Ad{}, fmt.Errorf("can't scan ad: %w", err)
+	}
+
+	return ad, nil
+}
+
+const storeAdQuery = `INSERT INTO ads (title, text, author_id, published, creation_date, update_date)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+func (r *RepositoryPG) StoreAd(ctx context.Context, ad *ads.Ad) (int64, error) {
+	var adID int64
+
+	err := r.conn(ctx).QueryRow(ctx, storeAdQuery, ad.Title, ad.Text, ad.AuthorID, ad.Published, ad.CreationDate, nullTime(ad.UpdateDate)).Scan(&adID)
+
+	if err != nil {
+		return 0, fmt.Errorf("can't insert ad: %w", err)
+	}
+
+	return adID, nil
+}
+
+const updateAdQuery = `UPDATE ads SET title = $2, text = $3, update_date = $4 WHERE id = $1`
+
+func (r *RepositoryPG) UpdateADByID(ctx context.Context, adID int64, title string, text string) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateAdQuery, adID, title, text, time.Now().UTC())
+
+	if err != nil {
+		return fmt.Errorf("can't update ad: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const updateAdStatusQuery = `UPDATE ads SET published = $2, update_date = $3 WHERE id = $1`
+
+func (r *RepositoryPG) UpdateAdStatus(ctx context.Context, adID int64, status bool) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateAdStatusQuery, adID, status, time.Now().UTC())
+
+	if err != nil {
+		return fmt.Errorf("can't update ad status: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const getUserByIDQuery = `SELECT id, nickname, email FROM users WHERE id = $1`
+
+func (r *RepositoryPG) GetUserByID(ctx context.Context, userID int64) (*users.User, error) {
+	user := &users.User{}
+
+	err := r.conn(ctx).QueryRow(ctx, getUserByIDQuery, userID).Scan(&user.ID, &user.Nickname, &user.Email)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &users.User{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &users.User{}, fmt.Errorf("can't scan user: %w", err)
+	}
+
+	return user, nil
+}
+
+const storeUserQuery = `INSERT INTO users (nickname, email) VALUES ($1, $2) RETURNING id`
+
+func (r *RepositoryPG) StoreUser(ctx context.Context, user *users.User) (int64, error) {
+	var userID int64
+
+	err := r.conn(ctx).QueryRow(ctx, storeUserQuery, user.Nickname, user.Email).Scan(&userID)
+
+	if err != nil {
+		return 0, fmt.Errorf("can't insert user: %w", err)
+	}
+
+	return userID, nil
+}
+
+const updateUserQuery = `UPDATE users SET nickname = $2, email = $3 WHERE id = $1`
+
+func (r *RepositoryPG) UpdateUserByID(ctx context.Context, userID int64, nickname string, email string) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateUserQuery, userID, nickname, email)
+
+	if err != nil {
+		return fmt.Errorf("can't update user: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const searchAdByNameQuery = `SELECT ` + adColumns + ` FROM ads WHERE deleted_at IS NULL AND strpos(title, $1) > 0 ORDER BY id LIMIT 1`
+
+func (r *RepositoryPG) SearchAdByName(ctx context.Context, adName string) (*ads.Ad, error) {
+	ad, err := scanAd(r.conn(ctx).QueryRow(ctx, searchAdByNameQuery, adName))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &ads.Ad{}, fmt.Errorf("can't scan ad: %w", err)
+	}
+
+	return ad, nil
+}
+
+const searchAdsQuery = `SELECT ` + adColumns + ` FROM ads, to_tsquery('simple', $1) query
+	WHERE published AND deleted_at IS NULL AND search @@ query
+	ORDER BY ts_rank(search, query) DESC, id
+	LIMIT $2`
+
+func (r *RepositoryPG) SearchAds(ctx context.Context, query string, limit int) ([]*ads.Ad, error) {
+	return r.selectAds(ctx, searchAdsQuery, search.PrefixQuery(query), limit)
+}
+
+// sortColumns задает выражения сортировки, совпадающие с порядком app.Filter.Less:
+// объявление без обновлений имеет нулевую дату, заголовки сравниваются побайтово
+var sortColumns = map[app.SortField]string{
+	app.SortByCreationDate: `creation_date`,
+	app.SortByUpdateDate:   `COALESCE(update_date, '0001-01-01 00:00:00+00')`,
+	app.SortByTitle:        `title COLLATE "C"`,
+}
+
+func (r *RepositoryPG) FilterAds(ctx context.Context, filter *app.Filter) ([]*ads.Ad, error) {
+	conds := []string{"deleted_at IS NULL"}
+	args := []any{}
+
+	switch filter.Status {
+	case app.StatusPublished:
+		conds = append(conds, "published")
+	case app.StatusUnpublished:
+		conds = append(conds, "NOT published")
+	}
+
+	if filter.AuthorID != -1 {
+		args = append(args, filter.AuthorID)
+		conds = append(conds, fmt.Sprintf("author_id = $%d", len(args)))
+	}
+	if !filter.PublishedAfter.IsZero() {
+		args = append(args, filter.PublishedAfter)
+		conds = append(conds, fmt.Sprintf("creation_date >= $%d", len(args)))
+	}
+	if !filter.PublishedBefore.IsZero() {
+		args = append(args, filter.PublishedBefore)
+		conds = append(conds, fmt.Sprintf("creation_date <= $%d", len(args)))
+	}
+	if filter.TitleContains != "" {
+		args = append(args, filter.TitleContains)
+		conds = append(conds, fmt.Sprintf("strpos(lower(title), lower($%d)) > 0", len(args)))
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		args = append(args, filter.UpdatedAfter)
+		conds = append(conds, fmt.Sprintf("update_date >= $%d", len(args)))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		args = append(args, filter.UpdatedBefore)
+		conds = append(conds, fmt.Sprintf("update_date <= $%d", len(args)))
+	}
+
+	sortColumn := sortColumns[filter.SortBy]
+	direction, cmp := "ASC", ">"
+	if filter.Descending {
+		direction, cmp = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		var key any = filter.After.Date
+		if filter.SortBy == app.SortByTitle {
+			key = filter.After.Title
+		}
+		args = append(args, key, filter.After.ID)
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d, $%d)", sortColumn, cmp, len(args)-1, len(args)))
+	}
+
+	q := `SELECT ` + adColumns + ` FROM ads WHERE ` + strings.Join(conds, " AND ") +
+		fmt.Sprintf(" ORDER BY %s %s, id %s", sortColumn, direction, direction)
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		q += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return r.selectAds(ctx, q, args...)
+}
+
+const setAdDeletedAtQuery = `UPDATE ads SET deleted_at = $2 WHERE id = $1`
+
+func (r *RepositoryPG) SetAdDeletedAt(ctx context.Context, adID int64, deletedAt time.Time) error {
+	tag, err := r.conn(ctx).Exec(ctx, setAdDeletedAtQuery, adID, nullTime(deletedAt))
+
+	if err != nil {
+		return fmt.Errorf("can't update ad deleted_at: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const purgeAdsQuery = `DELETE FROM ads WHERE deleted_at <= $1`
+
+func (r *RepositoryPG) PurgeAds(ctx context.Context, before time.Time) (int, error) {
+	tag, err := r.conn(ctx).Exec(ctx, purgeAdsQuery, before)
+
+	if err != nil {
+		return 0, fmt.Errorf("can't purge ads: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+const deleteAdsByAuthorQuery = `DELETE FROM ads WHERE author_id = $1`
+
+func (r *RepositoryPG) DeleteAdsByAuthor(ctx context.Context, authorID int64) error {
+	if _, err := r.conn(ctx).Exec(ctx, deleteAdsByAuthorQuery, authorID); err != nil {
+		return fmt.Errorf("can't delete ads: %w", err)
+	}
+
+	return nil
+}
+
+const deleteUserQuery = `DELETE FROM users WHERE id = $1`
+
+func (r *RepositoryPG) DeleteUser(ctx context.Context, userID int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, deleteUserQuery, userID)
+
+	if err != nil {
+		return fmt.Errorf("can't delete user: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *RepositoryPG) selectAds(ctx context.Context, q string, args ...any) ([]*ads.Ad, error) {
+	rows, err := r.conn(ctx).Query(ctx, q, args...)
+	if err != nil {
+		return []*ads.Ad{}, fmt.Errorf("can't select ads: %w", err)
+	}
+
+	defer rows.Close()
+
+	res := []*ads.Ad{}
+
+	for rows.Next() {
+		ad, err := scanAd(rows)
+		if err != nil {
+			return []*ads.Ad{}, fmt.Errorf("can't scan ad: %w", err)
+		}
+
+		res = append(res, ad)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []*ads.Ad{}, fmt.Errorf("can't select ads: %w", err)
+	}
+
+	if len(res) == 0 {
+		return res, ErrNotFound
+	}
+
+	return res, nil
+}
@@ -10,4 +10,5 @@ type Ad struct {
 	Published    bool
 	CreationDate time.Time
 	UpdateDate   time.Time
+	DeletedAt    time.Time // нулевое время - объявление не удалено
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/InfinityMeta/validator"
 
 	"homework8/internal/ads"
+	"homework8/internal/search"
 	"homework8/internal/users"
 )
 
@@ -17,106 +18,225 @@ var (
 	ErrNotValid        = errors.New("not valid")
 )
 
+const DefaultSearchLimit = 20
+
+const (
+	DefaultRestoreWindow = 7 * 24 * time.Hour
+	DefaultRetention     = 30 * 24 * time.Hour
+)
+
 type App interface {
 	// TODO: реализовать
 	CreateAd(context.Context, string, string, int64) (*ads.Ad, error)
-	CreateUser(context.Context, string, string) *users.User
+	CreateUser(context.Context, string, string) (*users.User, error)
 	ChangeAdStatus(context.Context, int64, int64, bool) (*ads.Ad, error)
 	UpdateAd(context.Context, int64, int64, string, string) (*ads.Ad, error)
 	UpdateUser(context.Context, int64, string, string) (*users.User, error)
 	CheckUserExists(context.Context, int64) bool
 	GetAdByID(context.Context, int64) (*ads.Ad, error)
 	SearchAdByName(context.Context, string) (*ads.Ad, error)
-	FilterAds(context.Context, ...FilterOption) ([]*ads.Ad, error)
+	SearchAds(context.Context, string, int) ([]*ads.Ad, error)
+	FilterAds(context.Context, ...FilterOption) ([]*ads.Ad, string, error)
+	// DeleteAd помечает объявление удаленным, автор может восстановить его в течение окна восстановления
+	DeleteAd(context.Context, int64, int64) (*ads.Ad, error)
+	RestoreAd(context.Context, int64, int64) (*ads.Ad, error)
+	// PurgeDeletedAds окончательно удаляет объявления, удаленные раньше срока хранения, и возвращает их количество
+	PurgeDeletedAds(context.Context) (int, error)
+	// DeleteUser удаляет пользователя вместе со всеми его объявлениями
+	DeleteUser(context.Context, int64) (*users.User, error)
 }
 
 type Repository interface {
 	// TODO: реализовать
-	StoreAd(context.Context, *ads.Ad)
-	StoreUser(context.Context, *users.User)
+	// StoreAd и StoreUser сохраняют сущность под новым уникальным ID, выданным репозиторием, и возвращают его
+	StoreAd(context.Context, *ads.Ad) (int64, error)
+	StoreUser(context.Context, *users.User) (int64, error)
+	// GetAdByID возвращает объявление, в том числе помеченное удаленным
 	GetAdByID(context.Context, int64) (*ads.Ad, error)
 	GetUserByID(context.Context, int64) (*users.User, error)
-	UpdateAdStatus(context.Context, int64, bool)
-	UpdateADByID(context.Context, int64, string, string)
-	UpdateUserByID(context.Context, int64, string, string)
-	LenAd(context.Context) int64
-	LenUser(context.Context) int64
+	UpdateAdStatus(context.Context, int64, bool) error
+	UpdateADByID(context.Context, int64, string, string) error
+	UpdateUserByID(context.Context, int64, string, string) error
+	// SearchAdByName, SearchAds и FilterAds не возвращают удаленные объявления
 	SearchAdByName(context.Context, string) (*ads.Ad, error)
+	// SearchAds ищет опубликованные объявления по словам запроса в заголовке и тексте,
+	// возвращает не более limit объявлений по убыванию релевантности
+	SearchAds(context.Context, string, int) ([]*ads.Ad, error)
+	// FilterAds возвращает объявления в порядке сортировки фильтра, начиная после filter.After,
+	// не более filter.Limit штук (0 - без ограничения)
 	FilterAds(context.Context, *Filter) ([]*ads.Ad, error)
+	// SetAdDeletedAt помечает объявление удаленным в момент deletedAt, нулевое время снимает пометку
+	SetAdDeletedAt(context.Context, int64, time.Time) error
+	// PurgeAds окончательно удаляет объявления, помеченные удаленными не позже before, и возвращает их количество
+	PurgeAds(context.Context, time.Time) (int, error)
+	// DeleteAdsByAuthor удаляет все объявления автора, отсутствие объявлений ошибкой не считается
+	DeleteAdsByAuthor(context.Context, int64) error
+	DeleteUser(context.Context, int64) error
+	// WithinTransaction выполняет fn как единицу работы: вызовы репозитория с контекстом,
+	// переданным в fn, применяются атомарно и откатываются, если fn вернула ошибку
+	WithinTransaction(context.Context, func(context.Context) error) error
 }
 
 type AdApp struct {
-	repository Repository
+	repository    Repository
+	restoreWindow time.Duration
+	retention     time.Duration
+	now           func() time.Time
 }
 
-func NewApp(repo Repository) App {
-	return &AdApp{repository: repo}
+type Option func(*AdApp)
+
+// WithRestoreWindow задает, сколько времени после удаления автор может восстановить объявление
+func WithRestoreWindow(d time.Duration) Option {
+	return func(a *AdApp) {
+		a.restoreWindow = d
+	}
 }
 
-func (a *AdApp) CreateAd(ctx context.Context, title string, text string, authorId int64) (*ads.Ad, error) {
+// WithRetention задает, сколько времени удаленное объявление хранится до окончательного удаления
+func WithRetention(d time.Duration) Option {
+	return func(a *AdApp) {
+		a.retention = d
+	}
+}
 
-	if !a.CheckUserExists(ctx, authorId) {
-		return &ads.Ad{}, ErrNotFound
+// WithClock подменяет источник текущего времени, используется в тестах
+func WithClock(now func() time.Time) Option {
+	return func(a *AdApp) {
+		a.now = now
+	}
+}
+
+func NewApp(repo Repository, options ...Option) App {
+	a := &AdApp{
+		repository:    repo,
+		restoreWindow: DefaultRestoreWindow,
+		retention:     DefaultRetention,
+		now:           time.Now,
+	}
+	for _, option := range options {
+		option(a)
 	}
+	return a
+}
+
+func (a *AdApp) CreateAd(ctx context.Context, title string, text string, authorId int64) (*ads.Ad, error) {
+
+	var ad *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if !a.CheckUserExists(ctx, authorId) {
+			return ErrNotFound
+		}
+
+		ad = &ads.Ad{Title: title, Text: text, AuthorID: authorId, Published: false, CreationDate: a.now().UTC(), UpdateDate: time.Time{}}
 
-	ad := &ads.Ad{ID: a.repository.LenAd(ctx), Title: title, Text: text, AuthorID: authorId, Published: false, CreationDate: time.Now().UTC(), UpdateDate: time.Time{}}
+		if err := validator.Validate(ad); err != nil {
+			return ErrNotValid
+		}
 
-	err := validator.Validate(ad)
+		adID, err := a.repository.StoreAd(ctx, ad)
+
+		if err != nil {
+			return err
+		}
+
+		ad.ID = adID
+
+		return nil
+
+	})
 
 	if err != nil {
-		return &ads.Ad{}, ErrNotValid
+		return &ads.Ad{}, err
 	}
 
-	a.repository.StoreAd(ctx, ad)
-
 	return ad, nil
 
 }
 
-func (a *AdApp) ChangeAdStatus(ctx context.Context, adID int64, authorID int64, published bool) (*ads.Ad, error) {
+// getOwnAd возвращает объявление, если его автор - authorID
+func (a *AdApp) getOwnAd(ctx context.Context, adID int64, authorID int64) (*ads.Ad, error) {
 
 	if !a.CheckUserExists(ctx, authorID) {
 		return &ads.Ad{}, ErrNotFound
 	}
 
-	ad, err := a.repository.GetAdByID(ctx, adID)
+	ad, err := a.GetAdByID(ctx, adID)
 
 	if err != nil {
-		return &ads.Ad{}, ErrNotFound
+		return &ads.Ad{}, err
 	}
 
 	if ad.AuthorID != authorID {
 		return &ads.Ad{}, ErrStatusForbidden
 	}
 
-	a.repository.UpdateAdStatus(ctx, adID, published)
-
 	return ad, nil
 
 }
 
-func (a *AdApp) UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*ads.Ad, error) {
+func (a *AdApp) ChangeAdStatus(ctx context.Context, adID int64, authorID int64, published bool) (*ads.Ad, error) {
 
-	if !a.CheckUserExists(ctx, authorID) {
-		return &ads.Ad{}, ErrNotFound
-	}
+	var ad *ads.Ad
 
-	ad, err := a.repository.GetAdByID(ctx, adID)
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := a.getOwnAd(ctx, adID, authorID); err != nil {
+			return err
+		}
+
+		if err := a.repository.UpdateAdStatus(ctx, adID, published); err != nil {
+			return err
+		}
+
+		var err error
+		ad, err = a.GetAdByID(ctx, adID)
+
+		return err
+
+	})
 
 	if err != nil {
-		return &ads.Ad{}, ErrNotFound
+		return &ads.Ad{}, err
 	}
 
-	if ad.AuthorID != authorID {
-		return &ads.Ad{}, ErrStatusForbidden
-	}
+	return ad, nil
+
+}
+
+func (a *AdApp) UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*ads.Ad, error) {
 
-	a.repository.UpdateADByID(ctx, adID, title, text)
+	var ad *ads.Ad
 
-	err = validator.Validate(ad)
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		current, err := a.getOwnAd(ctx, adID, authorID)
+
+		if err != nil {
+			return err
+		}
+
+		current.Title = title
+		current.Text = text
+
+		if err := validator.Validate(current); err != nil {
+			return ErrNotValid
+		}
+
+		if err := a.repository.UpdateADByID(ctx, adID, title, text); err != nil {
+			return err
+		}
+
+		ad, err = a.GetAdByID(ctx, adID)
+
+		return err
+
+	})
 
 	if err != nil {
-		return &ads.Ad{}, ErrNotValid
+		return &ads.Ad{}, err
 	}
 
 	return ad, nil
@@ -131,30 +251,63 @@ func (a *AdApp) CheckUserExists(ctx context.Context, userID int64) bool {
 
 }
 
-func (a *AdApp) CreateUser(ctx context.Context, nickname string, email string) *users.User {
+func (a *AdApp) CreateUser(ctx context.Context, nickname string, email string) (*users.User, error) {
+
+	var user *users.User
 
-	user := &users.User{ID: a.repository.LenUser(ctx), Nickname: nickname, Email: email}
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
 
-	a.repository.StoreUser(ctx, user)
+		user = &users.User{Nickname: nickname, Email: email}
 
-	return user
+		userID, err := a.repository.StoreUser(ctx, user)
+
+		if err != nil {
+			return err
+		}
+
+		user.ID = userID
+
+		return nil
+
+	})
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	return user, nil
 
 }
 
 func (a *AdApp) UpdateUser(ctx context.Context, userID int64, nickname string, email string) (*users.User, error) {
 
-	if !a.CheckUserExists(ctx, userID) {
-		return &users.User{}, ErrNotFound
-	}
+	var user *users.User
 
-	user, err := a.repository.GetUserByID(ctx, userID)
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if !a.CheckUserExists(ctx, userID) {
+			return ErrNotFound
+		}
+
+		if err := a.repository.UpdateUserByID(ctx, userID, nickname, email); err != nil {
+			return err
+		}
+
+		var err error
+		user, err = a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		return nil
+
+	})
 
 	if err != nil {
-		return &users.User{}, ErrNotFound
+		return &users.User{}, err
 	}
 
-	a.repository.UpdateUserByID(ctx, userID, nickname, email)
-
 	return user, nil
 
 }
@@ -163,7 +316,7 @@ func (a *AdApp) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, error) {
 
 	ad, err := a.repository.GetAdByID(ctx, adID)
 
-	if err != nil {
+	if err != nil || !ad.DeletedAt.IsZero() {
 		return &ads.Ad{}, ErrNotFound
 	}
 
@@ -183,14 +336,173 @@ func (a *AdApp) SearchAdByName(ctx context.Context, adName string) (*ads.Ad, err
 
 }
 
-func (a *AdApp) FilterAds(ctx context.Context, options ...FilterOption) ([]*ads.Ad, error) {
+func (a *AdApp) SearchAds(ctx context.Context, query string, limit int) ([]*ads.Ad, error) {
+
+	if len(search.Tokenize(query)) == 0 {
+		return []*ads.Ad{}, ErrNotValid
+	}
+
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+
+	if limit < 0 || limit > MaxLimit {
+		return []*ads.Ad{}, ErrNotValid
+	}
 
-	filteredAds, err := a.repository.FilterAds(ctx, NewFilter(options...))
+	foundAds, err := a.repository.SearchAds(ctx, query, limit)
 
 	if err != nil {
 		return []*ads.Ad{}, ErrNotFound
 	}
 
-	return filteredAds, nil
+	return foundAds, nil
+
+}
+
+func (a *AdApp) FilterAds(ctx context.Context, options ...FilterOption) ([]*ads.Ad, string, error) {
+
+	filter := NewFilter(options...)
+
+	if err := filter.prepare(); err != nil {
+		return []*ads.Ad{}, "", err
+	}
+
+	// неопубликованные объявления видит только их автор, поэтому без фильтра по автору они не отдаются
+	if filter.Status != StatusPublished && filter.AuthorID == -1 {
+		return []*ads.Ad{}, "", ErrStatusForbidden
+	}
+
+	// запрашиваем на одно объявление больше, чтобы понять, есть ли следующая страница
+	query := *filter
+	if filter.Limit > 0 {
+		query.Limit = filter.Limit + 1
+	}
+
+	filteredAds, err := a.repository.FilterAds(ctx, &query)
+
+	if err != nil {
+		return []*ads.Ad{}, "", ErrNotFound
+	}
+
+	nextCursor := ""
+
+	if filter.Limit > 0 && len(filteredAds) > filter.Limit {
+		filteredAds = filteredAds[:filter.Limit]
+		nextCursor = newCursor(filter, filteredAds[len(filteredAds)-1]).Encode()
+	}
+
+	return filteredAds, nextCursor, nil
+
+}
+
+func (a *AdApp) DeleteAd(ctx context.Context, adID int64, authorID int64) (*ads.Ad, error) {
+
+	var ad *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := a.getOwnAd(ctx, adID, authorID); err != nil {
+			return err
+		}
+
+		if err := a.repository.SetAdDeletedAt(ctx, adID, a.now().UTC()); err != nil {
+			return err
+		}
+
+		var err error
+		ad, err = a.repository.GetAdByID(ctx, adID)
+
+		return err
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	return ad, nil
+
+}
+
+// RestoreAd снимает пометку об удалении, если с момента удаления прошло не больше окна восстановления
+func (a *AdApp) RestoreAd(ctx context.Context, adID int64, authorID int64) (*ads.Ad, error) {
+
+	var ad *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if !a.CheckUserExists(ctx, authorID) {
+			return ErrNotFound
+		}
+
+		deleted, err := a.repository.GetAdByID(ctx, adID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if deleted.AuthorID != authorID {
+			return ErrStatusForbidden
+		}
+
+		if deleted.DeletedAt.IsZero() {
+			return ErrNotValid
+		}
+
+		if a.now().Sub(deleted.DeletedAt) > a.restoreWindow {
+			return ErrNotFound
+		}
+
+		if err := a.repository.SetAdDeletedAt(ctx, adID, time.Time{}); err != nil {
+			return err
+		}
+
+		ad, err = a.GetAdByID(ctx, adID)
+
+		return err
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	return ad, nil
+
+}
+
+func (a *AdApp) PurgeDeletedAds(ctx context.Context) (int, error) {
+
+	return a.repository.PurgeAds(ctx, a.now().Add(-a.retention).UTC())
+
+}
+
+func (a *AdApp) DeleteUser(ctx context.Context, userID int64) (*users.User, error) {
+
+	var user *users.User
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		var err error
+		user, err = a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if err := a.repository.DeleteAdsByAuthor(ctx, userID); err != nil {
+			return err
+		}
+
+		return a.repository.DeleteUser(ctx, userID)
+
+	})
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	return user, nil
 
 }
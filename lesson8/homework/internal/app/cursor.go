@@ -0,0 +1,161 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"homework8/internal/ads"
+)
+
+const MaxLimit = 100
+
+type SortField string
+
+const (
+	SortByCreationDate SortField = "creation_date"
+	SortByUpdateDate   SortField = "update_date"
+	SortByTitle        SortField = "title"
+)
+
+func (s SortField) Valid() bool {
+	switch s {
+	case SortByCreationDate, SortByUpdateDate, SortByTitle:
+		return true
+	}
+	return false
+}
+
+// Cursor - позиция последнего объявления на странице в порядке сортировки фильтра.
+// Следующая страница начинается строго после нее, ID разрешает равенство ключей сортировки.
+type Cursor struct {
+	SortBy     SortField `json:"s"`
+	Descending bool      `json:"d"`
+	ID         int64     `json:"i"`
+	Title      string    `json:"t,omitempty"`
+	Date       time.Time `json:"c"`
+}
+
+func newCursor(filter *Filter, ad *ads.Ad) *Cursor {
+	cursor := &Cursor{SortBy: filter.SortBy, Descending: filter.Descending, ID: ad.ID}
+
+	switch filter.SortBy {
+	case SortByTitle:
+		cursor.Title = ad.Title
+	case SortByUpdateDate:
+		cursor.Date = ad.UpdateDate
+	default:
+		cursor.Date = ad.CreationDate
+	}
+
+	return cursor
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrNotValid
+	}
+
+	cursor := &Cursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, ErrNotValid
+	}
+
+	return cursor, nil
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// compareAds сравнивает объявления по полю сортировки, а при равенстве - по ID
+func compareAds(sortBy SortField, a, b *ads.Ad) int {
+	var res int
+
+	switch sortBy {
+	case SortByTitle:
+		res = strings.Compare(a.Title, b.Title)
+	case SortByUpdateDate:
+		res = compareTime(a.UpdateDate, b.UpdateDate)
+	default:
+		res = compareTime(a.CreationDate, b.CreationDate)
+	}
+
+	if res != 0 {
+		return res
+	}
+
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+
+	return 0
+}
+
+// Less сообщает, должно ли объявление a идти раньше b в выдаче по фильтру
+func (f *Filter) Less(a, b *ads.Ad) bool {
+	if f.Descending {
+		return compareAds(f.SortBy, a, b) > 0
+	}
+	return compareAds(f.SortBy, a, b) < 0
+}
+
+// AfterCursor сообщает, идет ли объявление строго после курсора в выдаче по фильтру
+func (f *Filter) AfterCursor(ad *ads.Ad) bool {
+	if f.After == nil {
+		return true
+	}
+
+	pivot := &ads.Ad{ID: f.After.ID, Title: f.After.Title, CreationDate: f.After.Date, UpdateDate: f.After.Date}
+
+	return f.Less(pivot, ad)
+}
+
+// prepare проверяет параметры фильтра и разбирает курсор
+func (f *Filter) prepare() error {
+	if !f.Status.Valid() {
+		return ErrNotValid
+	}
+
+	if !f.SortBy.Valid() {
+		return ErrNotValid
+	}
+
+	if f.Limit < 0 || f.Limit > MaxLimit {
+		return ErrNotValid
+	}
+
+	if f.Cursor == "" {
+		f.After = nil
+		return nil
+	}
+
+	cursor, err := DecodeCursor(f.Cursor)
+	if err != nil {
+		return err
+	}
+
+	if cursor.SortBy != f.SortBy || cursor.Descending != f.Descending {
+		return ErrNotValid
+	}
+
+	f.After = cursor
+
+	return nil
+}
@@ -1,11 +1,46 @@
 package app
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"homework8/internal/ads"
+)
+
+type PublishStatus string
+
+const (
+	StatusPublished   PublishStatus = "published"
+	StatusUnpublished PublishStatus = "unpublished"
+	StatusAll         PublishStatus = "all"
+)
+
+func (s PublishStatus) Valid() bool {
+	switch s {
+	case StatusPublished, StatusUnpublished, StatusAll:
+		return true
+	}
+	return false
+}
 
 type Filter struct {
 	AuthorID        int64
 	PublishedAfter  time.Time
 	PublishedBefore time.Time
+	Status          PublishStatus
+	// TitleContains - подстрока заголовка без учета регистра
+	TitleContains string
+	// UpdatedAfter и UpdatedBefore отбирают только объявления, которые хотя бы раз обновлялись
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	SortBy        SortField
+	Descending    bool
+	// Limit - размер страницы, 0 - без ограничения
+	Limit int
+	// Cursor - непрозрачный курсор из next_cursor предыдущей страницы
+	Cursor string
+	// After - разобранный Cursor, заполняется приложением перед обращением к репозиторию
+	After *Cursor
 }
 
 type FilterOption func(*Filter)
@@ -15,6 +50,14 @@ func NewFilter(options ...FilterOption) *Filter {
 		AuthorID:        -1,
 		PublishedAfter:  time.Time{},
 		PublishedBefore: time.Time{},
+		Status:          StatusPublished,
+		TitleContains:   "",
+		UpdatedAfter:    time.Time{},
+		UpdatedBefore:   time.Time{},
+		SortBy:          SortByCreationDate,
+		Descending:      false,
+		Limit:           0,
+		Cursor:          "",
 	}
 
 	for _, option := range options {
@@ -41,3 +84,86 @@ func WithPublishedBefore(publishedBefore time.Time) FilterOption {
 		filter.PublishedBefore = publishedBefore
 	}
 }
+
+func WithStatus(status PublishStatus) FilterOption {
+	return func(filter *Filter) {
+		filter.Status = status
+	}
+}
+
+func WithTitleContains(substr string) FilterOption {
+	return func(filter *Filter) {
+		filter.TitleContains = substr
+	}
+}
+
+func WithUpdatedAfter(updatedAfter time.Time) FilterOption {
+	return func(filter *Filter) {
+		filter.UpdatedAfter = updatedAfter
+	}
+}
+
+func WithUpdatedBefore(updatedBefore time.Time) FilterOption {
+	return func(filter *Filter) {
+		filter.UpdatedBefore = updatedBefore
+	}
+}
+
+func WithSort(sortBy SortField, descending bool) FilterOption {
+	return func(filter *Filter) {
+		filter.SortBy = sortBy
+		filter.Descending = descending
+	}
+}
+
+func WithLimit(limit int) FilterOption {
+	return func(filter *Filter) {
+		filter.Limit = limit
+	}
+}
+
+func WithCursor(cursor string) FilterOption {
+	return func(filter *Filter) {
+		filter.Cursor = cursor
+	}
+}
+
+// Match проверяет объявление на соответствие всем критериям фильтра, кроме курсора.
+// Удаленные объявления не подходят ни под один фильтр
+func (f *Filter) Match(ad *ads.Ad) bool {
+	if !ad.DeletedAt.IsZero() {
+		return false
+	}
+	switch f.Status {
+	case StatusPublished:
+		if !ad.Published {
+			return false
+		}
+	case StatusUnpublished:
+		if ad.Published {
+			return false
+		}
+	}
+	if f.AuthorID != -1 && ad.AuthorID != f.AuthorID {
+		return false
+	}
+	if !f.PublishedAfter.IsZero() && ad.CreationDate.Before(f.PublishedAfter) {
+		return false
+	}
+	if !f.PublishedBefore.IsZero() && ad.CreationDate.After(f.PublishedBefore) {
+		return false
+	}
+	if f.TitleContains != "" && !strings.Contains(strings.ToLower(ad.Title), strings.ToLower(f.TitleContains)) {
+		return false
+	}
+	if (!f.UpdatedAfter.IsZero() || !f.UpdatedBefore.IsZero()) && ad.UpdateDate.IsZero() {
+		return false
+	}
+	if !f.UpdatedAfter.IsZero() && ad.UpdateDate.Before(f.UpdatedAfter) {
+		return false
+	}
+	if !f.UpdatedBefore.IsZero() && ad.UpdateDate.After(f.UpdatedBefore) {
+		return false
+	}
+	return true
+}
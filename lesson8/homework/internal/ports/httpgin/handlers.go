@@ -4,7 +4,6 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -157,44 +156,122 @@ func getAdByID(a app.App) gin.HandlerFunc {
 	}
 }
 
-// Метод для получения опубликованных объявлений
+// Метод для удаления объявления его автором
+func deleteAd(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reqBody deleteAdRequest
+		err := c.ShouldBindJSON(&reqBody)
 
-func filterAds(a app.App) gin.HandlerFunc {
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		ad, err := a.DeleteAd(c, adID, reqBody.UserID)
+
+		if err != nil {
+			if errors.Is(err, app.ErrNotFound) {
+				c.JSON(http.StatusNotFound, AdErrorResponse(err))
+				return
+			}
+
+			if errors.Is(err, app.ErrStatusForbidden) {
+				c.JSON(http.StatusForbidden, AdErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, AdErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
+
+// Метод для восстановления удаленного объявления его автором
+func restoreAd(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var reqBody restoreAdRequest
+		err := c.ShouldBindJSON(&reqBody)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
 
-		authorID, err := strconv.ParseInt(c.Query("author_id"), 10, 64)
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, AdsErrorResponse(err))
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
 			return
 		}
 
-		pubBefore, err := time.Parse(time.RFC3339Nano, c.Query("pub_before"))
+		ad, err := a.RestoreAd(c, adID, reqBody.UserID)
 
 		if err != nil {
-			c.JSON(http.StatusBadRequest, AdsErrorResponse(err))
+			if errors.Is(err, app.ErrNotFound) {
+				c.JSON(http.StatusNotFound, AdErrorResponse(err))
+				return
+			}
+
+			if errors.Is(err, app.ErrStatusForbidden) {
+				c.JSON(http.StatusForbidden, AdErrorResponse(err))
+				return
+			}
+
+			if errors.Is(err, app.ErrNotValid) {
+				c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, AdErrorResponse(err))
 			return
 		}
 
-		pubAfter, err := time.Parse(time.RFC3339Nano, c.Query("pub_after"))
+		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+	}
+}
+
+// Метод для получения списка объявлений (по умолчанию только опубликованных).
+// Неопубликованные объявления отдаются только вместе с фильтром по автору
+
+func filterAds(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		options, err := bindFilterQuery(c)
 
 		if err != nil {
 			c.JSON(http.StatusBadRequest, AdsErrorResponse(err))
 			return
 		}
 
-		adsList, err := a.FilterAds(c, app.WithAuthorID(authorID), app.WithPublishedBefore(pubBefore.UTC()), app.WithPublishedAfter(pubAfter.UTC()))
+		adsList, nextCursor, err := a.FilterAds(c, options...)
 
 		if err != nil {
 			if errors.Is(err, app.ErrNotFound) {
 				c.JSON(http.StatusNotFound, AdErrorResponse(err))
 				return
 			}
+			if errors.Is(err, app.ErrStatusForbidden) {
+				c.JSON(http.StatusForbidden, AdsErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrNotValid) {
+				c.JSON(http.StatusBadRequest, AdsErrorResponse(err))
+				return
+			}
 			c.JSON(http.StatusInternalServerError, AdErrorResponse(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, AdsSuccessResponse(adsList))
+		c.JSON(http.StatusOK, AdsSuccessResponse(adsList, nextCursor))
 
 	}
 }
@@ -210,7 +287,12 @@ func createUser(a app.App) gin.HandlerFunc {
 			return
 		}
 
-		user := a.CreateUser(c, reqBody.Nickname, reqBody.Email)
+		user, err := a.CreateUser(c, reqBody.Nickname, reqBody.Email)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, UserErrorResponse(err))
+			return
+		}
 
 		c.JSON(http.StatusOK, UserSuccessResponse(user))
 	}
@@ -249,6 +331,31 @@ func updateUser(a app.App) gin.HandlerFunc {
 	}
 }
 
+// Метод для удаления пользователя вместе с его объявлениями
+func deleteUser(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, UserErrorResponse(err))
+			return
+		}
+
+		user, err := a.DeleteUser(c, userID)
+
+		if err != nil {
+			if errors.Is(err, app.ErrNotFound) {
+				c.JSON(http.StatusNotFound, UserErrorResponse(err))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, UserErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, UserSuccessResponse(user))
+	}
+}
+
 // Метод для получения объявления по имени
 
 func searchAdByName(a app.App) gin.HandlerFunc {
@@ -271,3 +378,40 @@ func searchAdByName(a app.App) gin.HandlerFunc {
 
 	}
 }
+
+// Метод для полнотекстового поиска опубликованных объявлений по заголовку и тексту
+
+func searchAds(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		limit := 0
+
+		if value, ok := c.GetQuery("limit"); ok {
+			var err error
+			limit, err = strconv.Atoi(value)
+
+			if err != nil {
+				c.JSON(http.StatusBadRequest, AdsErrorResponse(err))
+				return
+			}
+		}
+
+		adsList, err := a.SearchAds(c, c.Query("q"), limit)
+
+		if err != nil {
+			if errors.Is(err, app.ErrNotFound) {
+				c.JSON(http.StatusNotFound, AdsErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrNotValid) {
+				c.JSON(http.StatusBadRequest, AdsErrorResponse(err))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, AdsErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, AdsSuccessResponse(adsList, ""))
+
+	}
+}
@@ -1,6 +1,7 @@
 package httpgin
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +29,7 @@ type adResponse struct {
 	Published    bool      `json:"published"`
 	CreationDate time.Time `json:"creation_date"`
 	UpdateDate   time.Time `json:"update_date"`
+	DeletedAt    time.Time `json:"deleted_at"`
 }
 
 type userResponse struct {
@@ -47,6 +49,14 @@ type updateAdRequest struct {
 	UserID int64  `json:"user_id"`
 }
 
+type restoreAdRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+type deleteAdRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
 type updateUserRequest struct {
 	Nickname string `json:"nickname"`
 	Email    string `json:"email"`
@@ -62,6 +72,7 @@ func AdSuccessResponse(ad *ads.Ad) *gin.H {
 			Published:    ad.Published,
 			CreationDate: ad.CreationDate,
 			UpdateDate:   ad.UpdateDate,
+			DeletedAt:    ad.DeletedAt,
 		},
 		"error": nil,
 	}
@@ -74,7 +85,7 @@ func AdErrorResponse(err error) *gin.H {
 	}
 }
 
-func AdsSuccessResponse(ads []*ads.Ad) *gin.H {
+func AdsSuccessResponse(ads []*ads.Ad, nextCursor string) *gin.H {
 
 	resps := []adResponse{}
 
@@ -92,13 +103,24 @@ func AdsSuccessResponse(ads []*ads.Ad) *gin.H {
 	}
 
 	return &gin.H{
-		"data":  resps,
-		"error": nil,
+		"data":        resps,
+		"next_cursor": nextCursor,
+		"error":       nil,
 	}
 
 }
 
 func AdsErrorResponse(err error) *gin.H {
+	var queryErrs QueryErrors
+
+	if errors.As(err, &queryErrs) {
+		return &gin.H{
+			"data":    nil,
+			"error":   err.Error(),
+			"details": queryErrs,
+		}
+	}
+
 	return &gin.H{
 		"data":  nil,
 		"error": err.Error(),
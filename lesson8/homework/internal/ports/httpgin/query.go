@@ -0,0 +1,169 @@
+package httpgin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"homework8/internal/app"
+)
+
+const dateLayout = "2006-01-02"
+
+type queryParamError struct {
+	Param   string `json:"param"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// QueryErrors - ошибки разбора параметров запроса, по одной на каждый некорректный параметр
+type QueryErrors []queryParamError
+
+func (e QueryErrors) Error() string {
+	params := make([]string, 0, len(e))
+	for _, paramErr := range e {
+		params = append(params, paramErr.Param)
+	}
+	return "invalid query parameters: " + strings.Join(params, ", ")
+}
+
+// filterQuery разбирает параметры списка объявлений. Все параметры необязательные,
+// отсутствующий параметр оставляет значение фильтра по умолчанию.
+type filterQuery struct {
+	c       *gin.Context
+	options []app.FilterOption
+	errs    QueryErrors
+}
+
+func (q *filterQuery) fail(param string, value string, format string, args ...any) {
+	q.errs = append(q.errs, queryParamError{Param: param, Value: value, Message: fmt.Sprintf(format, args...)})
+}
+
+func (q *filterQuery) int64Param(param string, option func(int64) app.FilterOption) {
+	value, ok := q.c.GetQuery(param)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		q.fail(param, value, "must be an integer")
+		return
+	}
+
+	q.options = append(q.options, option(n))
+}
+
+func (q *filterQuery) intParam(param string, option func(int) app.FilterOption) {
+	value, ok := q.c.GetQuery(param)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		q.fail(param, value, "must be an integer")
+		return
+	}
+
+	q.options = append(q.options, option(n))
+}
+
+// timeParam принимает время в формате RFC3339 или дату 2006-01-02.
+// Дата для верхней границы (endOfDay) означает конец этого дня, для нижней - его начало.
+func (q *filterQuery) timeParam(param string, endOfDay bool, option func(time.Time) app.FilterOption) {
+	value, ok := q.c.GetQuery(param)
+	if !ok {
+		return
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t, err = time.Parse(dateLayout, value)
+		if err != nil {
+			q.fail(param, value, "must be a date (%s) or RFC3339 time", dateLayout)
+			return
+		}
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+
+	q.options = append(q.options, option(t.UTC()))
+}
+
+func (q *filterQuery) statusParam() {
+	value, ok := q.c.GetQuery("status")
+	if !ok {
+		return
+	}
+
+	status := app.PublishStatus(value)
+	if !status.Valid() {
+		q.fail("status", value, "must be one of %s, %s, %s", app.StatusPublished, app.StatusUnpublished, app.StatusAll)
+		return
+	}
+
+	q.options = append(q.options, app.WithStatus(status))
+}
+
+func (q *filterQuery) sortParams() {
+	sortValue, hasSort := q.c.GetQuery("sort")
+	orderValue, hasOrder := q.c.GetQuery("order")
+
+	if !hasSort && !hasOrder {
+		return
+	}
+
+	sortBy := app.SortByCreationDate
+	if hasSort {
+		sortBy = app.SortField(sortValue)
+		if !sortBy.Valid() {
+			q.fail("sort", sortValue, "must be one of %s, %s, %s", app.SortByCreationDate, app.SortByUpdateDate, app.SortByTitle)
+		}
+	}
+
+	descending := false
+	if hasOrder {
+		switch orderValue {
+		case "asc":
+		case "desc":
+			descending = true
+		default:
+			q.fail("order", orderValue, "must be asc or desc")
+		}
+	}
+
+	q.options = append(q.options, app.WithSort(sortBy, descending))
+}
+
+func bindFilterQuery(c *gin.Context) ([]app.FilterOption, error) {
+	q := &filterQuery{c: c}
+
+	q.int64Param("author_id", app.WithAuthorID)
+	q.timeParam("pub_after", false, app.WithPublishedAfter)
+	q.timeParam("pub_before", true, app.WithPublishedBefore)
+	q.timeParam("upd_after", false, app.WithUpdatedAfter)
+	q.timeParam("upd_before", true, app.WithUpdatedBefore)
+	q.statusParam()
+
+	if title, ok := c.GetQuery("title"); ok {
+		q.options = append(q.options, app.WithTitleContains(title))
+	}
+
+	q.sortParams()
+	q.intParam("limit", app.WithLimit)
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		q.options = append(q.options, app.WithCursor(cursor))
+	}
+
+	if len(q.errs) > 0 {
+		return nil, q.errs
+	}
+
+	return q.options, nil
+}
@@ -13,8 +13,12 @@ func AppRouter(r gin.IRouter, a app.App) {
 	r.PUT("/ads/:ad_id/status", changeAdStatus(a)) // Метод для изменения статуса объявления (опубликовано - Published = true или снято с публикации Published = false)
 	r.PUT("/ads/:ad_id", updateAd(a))              // Метод для обновления текста(Text) или заголовка(Title) объявления
 	r.GET("/ads/:ad_id", getAdByID(a))             // Метод для получения объявления по id
-	r.GET("/ads", filterAds(a))                    // Метод для получения опубликованных объявлений
+	r.DELETE("/ads/:ad_id", deleteAd(a))           // Метод для удаления объявления его автором
+	r.POST("/ads/:ad_id/restore", restoreAd(a))    // Метод для восстановления удаленного объявления его автором
+	r.GET("/ads", filterAds(a))                    // Метод для получения списка объявлений (по умолчанию только опубликованных)
 	r.POST("/users", createUser(a))                // Метод для создания пользователя (user)
 	r.PUT("/users/:user_id", updateUser(a))        // Метод для обновления никнейма(Nickname) или емейла(Email) пользователя
+	r.DELETE("/users/:user_id", deleteUser(a))     // Метод для удаления пользователя вместе с его объявлениями
 	r.GET("/ads/search/:title", searchAdByName(a)) // Метод для поиска объявления по названию
+	r.GET("/ads/search", searchAds(a))             // Метод для полнотекстового поиска объявлений
 }
@@ -0,0 +1,155 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	titleWeight = 2.0
+	textWeight  = 1.0
+	// prefixWeight - множитель для слова, которое совпало с запросом только по префиксу
+	prefixWeight = 0.5
+)
+
+// Tokenize приводит строку к нижнему регистру и разбивает ее на слова из букв и цифр
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type posting struct {
+	title int
+	text  int
+}
+
+type Result struct {
+	ID    int64
+	Score float64
+}
+
+// Index - инвертированный индекс по заголовку и тексту объявлений.
+// Index не потокобезопасен, синхронизация остается на стороне хранилища.
+type Index struct {
+	postings map[string]map[int64]*posting
+	docs     map[int64][]string
+}
+
+func NewIndex() *Index {
+	return &Index{postings: make(map[string]map[int64]*posting), docs: make(map[int64][]string)}
+}
+
+// Add индексирует документ, заменяя его предыдущую версию
+func (idx *Index) Add(id int64, title string, text string) {
+	idx.Remove(id)
+
+	terms := []string{}
+
+	add := func(tokens []string, inTitle bool) {
+		for _, token := range tokens {
+			docs, ok := idx.postings[token]
+			if !ok {
+				docs = make(map[int64]*posting)
+				idx.postings[token] = docs
+			}
+
+			p, ok := docs[id]
+			if !ok {
+				p = &posting{}
+				docs[id] = p
+				terms = append(terms, token)
+			}
+
+			if inTitle {
+				p.title++
+			} else {
+				p.text++
+			}
+		}
+	}
+
+	add(Tokenize(title), true)
+	add(Tokenize(text), false)
+
+	idx.docs[id] = terms
+}
+
+func (idx *Index) Remove(id int64) {
+	for _, term := range idx.docs[id] {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Search возвращает документы, в которых каждое слово запроса встречается целиком или как префикс слова документа.
+// Результаты отсортированы по убыванию релевантности, при равенстве - по ID.
+func (idx *Index) Search(query string) []Result {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return []Result{}
+	}
+
+	var scores map[int64]float64
+
+	for _, token := range tokens {
+		tokenScores := make(map[int64]float64)
+
+		for term, docs := range idx.postings {
+			if !strings.HasPrefix(term, token) {
+				continue
+			}
+
+			weight := 1.0
+			if term != token {
+				weight = prefixWeight
+			}
+
+			for id, p := range docs {
+				tokenScores[id] += weight * (titleWeight*float64(p.title) + textWeight*float64(p.text))
+			}
+		}
+
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+
+		for id := range scores {
+			if _, ok := tokenScores[id]; !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += tokenScores[id]
+		}
+	}
+
+	res := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		res = append(res, Result{ID: id, Score: score})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
+
+// PrefixQuery строит tsquery, эквивалентный Search: все слова запроса обязательны и ищутся по префиксу
+func PrefixQuery(query string) string {
+	tokens := Tokenize(query)
+
+	for i, token := range tokens {
+		tokens[i] = token + ":*"
+	}
+
+	return strings.Join(tokens, " & ")
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
@@ -290,3 +291,49 @@ func TestUserExist(t *testing.T) {
 	assert.ErrorIs(t, err, ErrNotFound)
 
 }
+
+func TestListAds(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	response, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	publishedAd, err := client.changeAdStatus(0, response.Data.ID, true)
+	assert.NoError(t, err)
+
+	_, err = client.createAd(0, "best cat", "not for sale")
+	assert.NoError(t, err)
+
+	ads, err := client.listAds()
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+	assert.Equal(t, ads.Data[0].ID, publishedAd.Data.ID)
+	assert.Equal(t, ads.Data[0].Title, publishedAd.Data.Title)
+	assert.Equal(t, ads.Data[0].Text, publishedAd.Data.Text)
+	assert.Equal(t, ads.Data[0].AuthorID, publishedAd.Data.AuthorID)
+	assert.True(t, ads.Data[0].Published)
+}
+
+func TestFilterAdsByDate(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.changeAdStatus(0, ad.Data.ID, true)
+	assert.NoError(t, err)
+
+	today := ad.Data.CreationDate.Format("2006-01-02")
+	tomorrow := ad.Data.CreationDate.AddDate(0, 0, 1).Format("2006-01-02")
+
+	ads, err := client.filterAdsQuery(url.Values{"pub_after": {today}, "pub_before": {today}})
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+
+	_, err = client.filterAdsQuery(url.Values{"pub_after": {tomorrow}})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"homework8/internal/app"
+)
+
+func TestFilterAdsByStatus(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	createPublishedAds(t, client, "published")
+
+	draft, err := client.createAd(0, "draft", "text")
+	assert.NoError(t, err)
+
+	_, err = client.createAd(1, "other draft", "text")
+	assert.NoError(t, err)
+
+	ads, err := client.filterAds(app.WithAuthorID(0), app.WithStatus(app.StatusUnpublished))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+	assert.Equal(t, ads.Data[0].ID, draft.Data.ID)
+	assert.False(t, ads.Data[0].Published)
+
+	ads, err = client.filterAds(app.WithAuthorID(0), app.WithStatus(app.StatusAll))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+
+	// без фильтра по автору чужие черновики не отдаются
+	_, err = client.filterAds(app.WithStatus(app.StatusAll))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.filterAdsQuery(url.Values{"status": {"unpublished"}})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.filterAdsQuery(url.Values{"status": {"deleted"}})
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestFilterAdsByTitle(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "Red Sedan", "blue sedan", "bicycle")
+
+	ads, err := client.filterAds(app.WithTitleContains("SEDAN"))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+
+	ads, err = client.filterAds(app.WithTitleContains("sedan"), app.WithSort(app.SortByTitle, false))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+	assert.Equal(t, ads.Data[0].ID, created[0].ID)
+
+	_, err = client.filterAds(app.WithTitleContains("truck"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFilterAdsByUpdateDate(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "first", "second", "third")
+
+	timePoint := time.Now().UTC()
+
+	_, err := client.updateAd(0, created[1].ID, "second", "updated")
+	assert.NoError(t, err)
+
+	ads, err := client.filterAds(app.WithUpdatedAfter(timePoint))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+	assert.Equal(t, ads.Data[0].ID, created[1].ID)
+
+	ads, err = client.filterAds(app.WithUpdatedBefore(timePoint))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+
+	ads, err = client.filterAds(app.WithUpdatedAfter(timePoint), app.WithTitleContains("first"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
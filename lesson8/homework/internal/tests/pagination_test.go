@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"homework8/internal/app"
+)
+
+func createPublishedAds(t *testing.T, client *testClient, titles ...string) []adData {
+	res := []adData{}
+
+	for _, title := range titles {
+		ad, err := client.createAd(0, title, "text")
+		assert.NoError(t, err)
+
+		ad, err = client.changeAdStatus(0, ad.Data.ID, true)
+		assert.NoError(t, err)
+
+		res = append(res, ad.Data)
+	}
+
+	return res
+}
+
+func TestFilterAdsPagination(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "a", "b", "c", "d", "e")
+
+	page, err := client.filterAds(app.WithLimit(2))
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, page.Data[0].ID, created[0].ID)
+	assert.Equal(t, page.Data[1].ID, created[1].ID)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = client.filterAds(app.WithLimit(2), app.WithCursor(page.NextCursor))
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, page.Data[0].ID, created[2].ID)
+	assert.Equal(t, page.Data[1].ID, created[3].ID)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = client.filterAds(app.WithLimit(2), app.WithCursor(page.NextCursor))
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, page.Data[0].ID, created[4].ID)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestFilterAdsSortByTitleDesc(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	createPublishedAds(t, client, "banana", "apple", "cherry", "apple")
+
+	page, err := client.filterAds(app.WithSort(app.SortByTitle, true), app.WithLimit(3))
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 3)
+	assert.Equal(t, page.Data[0].Title, "cherry")
+	assert.Equal(t, page.Data[1].Title, "banana")
+	assert.Equal(t, page.Data[2].Title, "apple")
+	assert.Equal(t, page.Data[2].ID, int64(3))
+
+	page, err = client.filterAds(app.WithSort(app.SortByTitle, true), app.WithLimit(3), app.WithCursor(page.NextCursor))
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, page.Data[0].Title, "apple")
+	assert.Equal(t, page.Data[0].ID, int64(1))
+}
+
+func TestFilterAdsSortByUpdateDate(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "first", "second")
+
+	_, err := client.updateAd(0, created[0].ID, "first", "updated")
+	assert.NoError(t, err)
+
+	page, err := client.filterAds(app.WithSort(app.SortByUpdateDate, true))
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, page.Data[0].ID, created[0].ID)
+	assert.Equal(t, page.Data[1].ID, created[1].ID)
+}
+
+func TestFilterAdsBadPagination(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	createPublishedAds(t, client, "a", "b", "c")
+
+	_, err := client.filterAds(app.WithCursor("not a cursor"))
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.filterAds(app.WithLimit(app.MaxLimit + 1))
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.filterAds(app.WithSort("price", false))
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	page, err := client.filterAds(app.WithLimit(1))
+	assert.NoError(t, err)
+
+	_, err = client.filterAds(app.WithLimit(1), app.WithSort(app.SortByTitle, false), app.WithCursor(page.NextCursor))
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"homework8/internal/adapters/adrepo"
+	"homework8/internal/app"
+)
+
+// testClock - управляемые тестом часы приложения
+type testClock struct {
+	mx  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func getTestClientWithClock() (*testClient, app.App, *testClock) {
+	clock := &testClock{now: time.Now()}
+	a := app.NewApp(adrepo.New(), app.WithClock(clock.Now), app.WithRestoreWindow(time.Hour), app.WithRetention(24*time.Hour))
+	return getTestClientWithApp(a), a, clock
+}
+
+func TestDeletedAdIsHidden(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ads := createPublishedAds(t, client, "hello")
+
+	response, err := client.deleteAd(0, ads[0].ID)
+	assert.NoError(t, err)
+	assert.False(t, response.Data.DeletedAt.IsZero())
+
+	_, err = client.getAdByID(ads[0].ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.listAds()
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.searchAdByName("hello")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.updateAd(0, ads[0].ID, "hi", "world")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.changeAdStatus(0, ads[0].ID, false)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRestoreAd(t *testing.T) {
+	client, _, clock := getTestClientWithClock()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ads := createPublishedAds(t, client, "hello")
+
+	_, err := client.deleteAd(0, ads[0].ID)
+	assert.NoError(t, err)
+
+	clock.Advance(30 * time.Minute)
+
+	response, err := client.restoreAd(0, ads[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, response.Data.DeletedAt.IsZero())
+	assert.True(t, response.Data.Published)
+
+	list, err := client.listAds()
+	assert.NoError(t, err)
+	assert.Len(t, list.Data, 1)
+
+	_, err = client.restoreAd(0, ads[0].ID)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestRestoreAdAfterWindow(t *testing.T) {
+	client, _, clock := getTestClientWithClock()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	assert.NoError(t, err)
+
+	clock.Advance(2 * time.Hour)
+
+	_, err = client.restoreAd(0, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRestoreAdByNotAuthor(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	assert.NoError(t, err)
+
+	_, err = client.restoreAd(1, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.restoreAd(0, 100)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPurgeDeletedAds(t *testing.T) {
+	client, a, clock := getTestClientWithClock()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ads := createPublishedAds(t, client, "old", "recent", "alive")
+
+	_, err := client.deleteAd(0, ads[0].ID)
+	assert.NoError(t, err)
+
+	clock.Advance(20 * time.Hour)
+
+	_, err = client.deleteAd(0, ads[1].ID)
+	assert.NoError(t, err)
+
+	clock.Advance(5 * time.Hour)
+
+	purged, err := a.PurgeDeletedAds(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	purged, err = a.PurgeDeletedAds(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+
+	_, err = client.getAdByID(ads[2].ID)
+	assert.NoError(t, err)
+}
+
+func TestRunPurgeStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := app.RunPurge(ctx, app.NewApp(adrepo.New()), time.Millisecond)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCreateAdUsesClock(t *testing.T) {
+	client, _, clock := getTestClientWithClock()
+	clock.Advance(-48 * time.Hour)
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+	assert.True(t, ad.Data.CreationDate.Equal(clock.Now().UTC()))
+}
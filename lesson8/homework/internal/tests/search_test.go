@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchAds(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "Red sedan", "Blue bicycle", "Garage sale")
+
+	_, err := client.updateAd(0, created[2].ID, "Garage sale", "old red sedan parts and a bicycle")
+	assert.NoError(t, err)
+
+	response, err := client.searchAds("SEDAN")
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, response.Data[0].ID, created[0].ID)
+	assert.Equal(t, response.Data[1].ID, created[2].ID)
+
+	response, err = client.searchAds("bicy red")
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, response.Data[0].ID, created[2].ID)
+
+	_, err = client.searchAds("tractor")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSearchAds_OnlyPublished(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	_, err := client.createAd(0, "draft sedan", "text")
+	assert.NoError(t, err)
+
+	published := createPublishedAds(t, client, "published sedan")
+
+	response, err := client.searchAds("sedan")
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, response.Data[0].ID, published[0].ID)
+}
+
+func TestSearchAds_Reindex(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "old title")
+
+	_, err := client.updateAd(0, created[0].ID, "new title", "text")
+	assert.NoError(t, err)
+
+	_, err = client.searchAds("old")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	response, err := client.searchAds("new")
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 1)
+}
+
+func TestSearchAds_EmptyQuery(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.searchAds("  ,. ")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
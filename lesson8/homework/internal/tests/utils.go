@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"time"
 
 	"homework8/internal/adapters/adrepo"
@@ -22,6 +24,7 @@ type adData struct {
 	Published    bool      `json:"published"`
 	CreationDate time.Time `json:"creation_date"`
 	UpdateDate   time.Time `json:"update_date"`
+	DeletedAt    time.Time `json:"deleted_at"`
 }
 
 type adResponse struct {
@@ -29,7 +32,8 @@ type adResponse struct {
 }
 
 type adsResponse struct {
-	Data []adData `json:"data"`
+	Data       []adData `json:"data"`
+	NextCursor string   `json:"next_cursor"`
 }
 
 type userData struct {
@@ -54,7 +58,15 @@ type testClient struct {
 }
 
 func getTestClient() *testClient {
-	server := httpgin.NewHTTPServer(":18080", app.NewApp(adrepo.New()))
+	return getTestClientWithRepo(adrepo.New())
+}
+
+func getTestClientWithRepo(repo app.Repository) *testClient {
+	return getTestClientWithApp(app.NewApp(repo))
+}
+
+func getTestClientWithApp(a app.App) *testClient {
+	server := httpgin.NewHTTPServer(":18080", a)
 	testServer := httptest.NewServer(server.Handler())
 
 	return &testClient{
@@ -195,6 +207,58 @@ func (tc *testClient) getAdByID(adID int64) (adResponse, error) {
 	return response, nil
 }
 
+func (tc *testClient) deleteAd(userID int64, adID int64) (adResponse, error) {
+	body := map[string]any{
+		"user_id": userID,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), bytes.NewReader(data))
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) restoreAd(userID int64, adID int64) (adResponse, error) {
+	body := map[string]any{
+		"user_id": userID,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/restore", adID), bytes.NewReader(data))
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
 func (tc *testClient) createUser(nickname string, email string) (userResponse, error) {
 	body := map[string]any{
 		"nickname": nickname,
@@ -249,6 +313,21 @@ func (tc *testClient) updateUser(userID int64, nickname string, email string) (u
 	return response, nil
 }
 
+func (tc *testClient) deleteUser(userID int64) (userResponse, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), nil)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
 func (tc *testClient) searchAdByName(title string) (adResponse, error) {
 
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/search/%s", title), nil)
@@ -271,7 +350,54 @@ func (tc *testClient) filterAds(options ...app.FilterOption) (adsResponse, error
 	authorID := filter.AuthorID
 	pubAfter := filter.PublishedAfter.Format(time.RFC3339Nano)
 	pubBefore := filter.PublishedBefore.Format(time.RFC3339Nano)
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads?author_id=%d&pub_after=%s&pub_before=%s", authorID, pubAfter, pubBefore), nil)
+	query := url.Values{}
+	query.Set("author_id", strconv.FormatInt(authorID, 10))
+	query.Set("pub_after", pubAfter)
+	query.Set("pub_before", pubBefore)
+	query.Set("status", string(filter.Status))
+	if filter.TitleContains != "" {
+		query.Set("title", filter.TitleContains)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		query.Set("upd_after", filter.UpdatedAfter.Format(time.RFC3339Nano))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		query.Set("upd_before", filter.UpdatedBefore.Format(time.RFC3339Nano))
+	}
+	query.Set("sort", string(filter.SortBy))
+	if filter.Descending {
+		query.Set("order", "desc")
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Cursor != "" {
+		query.Set("cursor", filter.Cursor)
+	}
+	return tc.filterAdsQuery(query)
+}
+
+func (tc *testClient) listAds() (adsResponse, error) {
+	return tc.filterAdsQuery(url.Values{})
+}
+
+func (tc *testClient) filterAdsQuery(query url.Values) (adsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads?"+query.Encode(), nil)
+	if err != nil {
+		return adsResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response adsResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adsResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) searchAds(query string) (adsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads/search?"+url.Values{"q": {query}}.Encode(), nil)
 	if err != nil {
 		return adsResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
@@ -1,6 +1,9 @@
 package tests
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -98,3 +101,50 @@ func TestUpdateAd_TooLongText(t *testing.T) {
 	_, err = client.updateAd(0, resp.Data.ID, "title", text)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
+
+func TestUpdateAd_InvalidIsNotPersisted(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.updateAd(0, resp.Data.ID, strings.Repeat("a", 101), "new_world")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	gotAd, err := client.getAdByID(resp.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, gotAd.Data.Title, "hello")
+	assert.Equal(t, gotAd.Data.Text, "world")
+	assert.True(t, gotAd.Data.UpdateDate.IsZero())
+}
+
+func TestFilterAds_InvalidQuery(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.filterAdsQuery(url.Values{"author_id": {"bob"}})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	resp, err := client.client.Get(client.baseURL + "/api/v1/ads?author_id=bob&pub_after=yesterday&limit=10&order=up")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var body struct {
+		Error   string `json:"error"`
+		Details []struct {
+			Param   string `json:"param"`
+			Value   string `json:"value"`
+			Message string `json:"message"`
+		} `json:"details"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	params := []string{}
+	for _, detail := range body.Details {
+		params = append(params, detail.Param)
+	}
+	assert.Equal(t, []string{"author_id", "pub_after", "order"}, params)
+	assert.Equal(t, "yesterday", body.Details[1].Value)
+}
@@ -0,0 +1,5 @@
+CREATE TABLE users (
+    id bigint primary key,
+    nickname text not null,
+    email text not null
+);
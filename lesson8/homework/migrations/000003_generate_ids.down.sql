@@ -0,0 +1,2 @@
+ALTER TABLE ads ALTER COLUMN id DROP IDENTITY;
+ALTER TABLE users ALTER COLUMN id DROP IDENTITY;
@@ -0,0 +1,5 @@
+ALTER TABLE ads ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', text), 'B')
+) STORED;
+
+CREATE INDEX ads_search_idx ON ads USING GIN (search);
@@ -0,0 +1,3 @@
+ALTER TABLE ads ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX ads_deleted_at_idx ON ads (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,716 @@
+package adrepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"homework9/internal/ads"
+	"homework9/internal/app"
+	"homework9/internal/audit"
+	"homework9/internal/search"
+	"homework9/internal/users"
+)
+
+var ErrNotFound = fmt.Errorf("not found")
+
+type StorageAd struct {
+	mx    *sync.RWMutex
+	data  map[int64]*ads.Ad
+	index *search.Index
+	// transitions - история переходов объявлений по их ID
+	transitions map[int64][]ads.Transition
+	// attachments - вложения объявлений по их ID в порядке добавления
+	attachments map[int64][]ads.Attachment
+}
+
+type StorageUser struct {
+	mx   *sync.RWMutex
+	data map[int64]*users.User
+}
+
+// StorageAudit - журнал аудита, записи упорядочены по ID и не удаляются вместе с объявлениями и пользователями
+type StorageAudit struct {
+	mx      *sync.RWMutex
+	entries []audit.Entry
+}
+
+type RepositoryApp struct {
+	storageAd    *StorageAd
+	storageUser  *StorageUser
+	storageAudit *StorageAudit
+	txMx         *sync.Mutex
+	adSeq        *atomic.Int64
+	userSeq      *atomic.Int64
+	auditSeq     *atomic.Int64
+	fileSeq      *atomic.Int64
+}
+
+func New() app.Repository {
+	storageAd := &StorageAd{mx: &sync.RWMutex{}, data: make(map[int64]*ads.Ad), index: search.NewIndex(), transitions: make(map[int64][]ads.Transition), attachments: make(map[int64][]ads.Attachment)}
+	storageUser := &StorageUser{mx: &sync.RWMutex{}, data: make(map[int64]*users.User)}
+	storageAudit := &StorageAudit{mx: &sync.RWMutex{}}
+	return &RepositoryApp{storageAd: storageAd, storageUser: storageUser, storageAudit: storageAudit, txMx: &sync.Mutex{}, adSeq: &atomic.Int64{}, userSeq: &atomic.Int64{}, auditSeq: &atomic.Int64{}, fileSeq: &atomic.Int64{}}
+}
+
+func (rs *RepositoryApp) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	res := *ad
+	res.Attachments = append([]ads.Attachment{}, rs.storageAd.attachments[adID]...)
+
+	return &res, nil
+
+}
+
+// StoreAd выдает ID из последовательности, которая, как и в postgres, не откатывается вместе с транзакцией
+func (rs *RepositoryApp) StoreAd(ctx context.Context, ad *ads.Ad) (int64, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	adID := rs.adSeq.Add(1) - 1
+
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
+
+		delete(rs.storageAd.data, adID)
+		rs.storageAd.index.Remove(adID)
+	})
+
+	stored := *ad
+	stored.ID = adID
+	rs.storageAd.data[adID] = &stored
+	rs.storageAd.index.Add(adID, stored.Title, stored.Text)
+
+	return adID, nil
+
+}
+
+func (rs *RepositoryApp) UpdateADByID(ctx context.Context, adID int64, title string, text string, details ads.Details, version int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	if version != 0 && ad.Version != version {
+		return app.ErrVersionMismatch
+	}
+
+	rs.rememberAd(ctx, ad)
+
+	ad.Title = title
+	ad.Text = text
+	ad.Details = details
+	ad.UpdateDate = time.Now().UTC()
+	ad.Version++
+	rs.storageAd.index.Add(adID, title, text)
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) UpdateAdState(ctx context.Context, adID int64, state ads.State, reason string) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.rememberAd(ctx, ad)
+
+	ad.State = state
+	ad.RejectionReason = reason
+	ad.UpdateDate = time.Now().UTC()
+	ad.Version++
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) StoreTransition(ctx context.Context, transition *ads.Transition) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	if _, ok := rs.storageAd.data[transition.AdID]; !ok {
+		return ErrNotFound
+	}
+
+	history := rs.storageAd.transitions[transition.AdID]
+
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
+
+		rs.storageAd.transitions[transition.AdID] = history
+	})
+
+	rs.storageAd.transitions[transition.AdID] = append(history, *transition)
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) GetTransitions(ctx context.Context, adID int64) ([]*ads.Transition, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Transition{}
+
+	for _, v := range rs.storageAd.transitions[adID] {
+		transition := v
+		res = append(res, &transition)
+	}
+
+	return res, nil
+
+}
+
+// StoreAttachment выдает ID из последовательности, которая не откатывается вместе с транзакцией
+func (rs *RepositoryApp) StoreAttachment(ctx context.Context, attachment *ads.Attachment) (int64, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	if _, ok := rs.storageAd.data[attachment.AdID]; !ok {
+		return 0, ErrNotFound
+	}
+
+	list := rs.storageAd.attachments[attachment.AdID]
+
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
+
+		rs.storageAd.attachments[attachment.AdID] = list
+	})
+
+	stored := *attachment
+	stored.ID = rs.fileSeq.Add(1) - 1
+	rs.storageAd.attachments[attachment.AdID] = append(list[:len(list):len(list)], stored)
+
+	return stored.ID, nil
+
+}
+
+func (rs *RepositoryApp) GetAttachment(ctx context.Context, adID int64, attachmentID int64) (*ads.Attachment, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	for _, v := range rs.storageAd.attachments[adID] {
+		if v.ID == attachmentID {
+			attachment := v
+			return &attachment, nil
+		}
+	}
+
+	return &ads.Attachment{}, ErrNotFound
+
+}
+
+func (rs *RepositoryApp) GetAttachments(ctx context.Context, adID int64) ([]*ads.Attachment, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Attachment{}
+
+	for _, v := range rs.storageAd.attachments[adID] {
+		attachment := v
+		res = append(res, &attachment)
+	}
+
+	return res, nil
+
+}
+
+func (rs *RepositoryApp) DeleteAttachment(ctx context.Context, adID int64, attachmentID int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	list := rs.storageAd.attachments[adID]
+
+	for i, v := range list {
+		if v.ID != attachmentID {
+			continue
+		}
+
+		rs.onRollback(ctx, func() {
+			rs.storageAd.mx.Lock()
+			defer rs.storageAd.mx.Unlock()
+
+			rs.storageAd.attachments[adID] = list
+		})
+
+		// новый срез, чтобы откат вернул исходный список нетронутым
+		rest := append([]ads.Attachment{}, list[:i]...)
+		rs.storageAd.attachments[adID] = append(rest, list[i+1:]...)
+
+		return nil
+	}
+
+	return ErrNotFound
+
+}
+
+func (rs *RepositoryApp) GetUserByID(ctx context.Context, userID int64) (*users.User, error) {
+	rs.storageUser.mx.RLock()
+	defer rs.storageUser.mx.RUnlock()
+
+	user, ok := rs.storageUser.data[userID]
+
+	if !ok {
+		return &users.User{}, ErrNotFound
+	}
+
+	res := *user
+
+	return &res, nil
+
+}
+
+func (rs *RepositoryApp) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	rs.storageUser.mx.RLock()
+	defer rs.storageUser.mx.RUnlock()
+
+	for _, user := range rs.storageUser.data {
+		if strings.EqualFold(user.Email, email) {
+			res := *user
+			return &res, nil
+		}
+	}
+
+	return &users.User{}, ErrNotFound
+
+}
+
+func (rs *RepositoryApp) StoreUser(ctx context.Context, user *users.User) (int64, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageUser.mx.Lock()
+	defer rs.storageUser.mx.Unlock()
+
+	if rs.emailTaken(user.Email, -1) {
+		return 0, app.ErrAlreadyExists
+	}
+
+	userID := rs.userSeq.Add(1) - 1
+
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		delete(rs.storageUser.data, userID)
+	})
+
+	stored := *user
+	stored.ID = userID
+	rs.storageUser.data[userID] = &stored
+
+	return userID, nil
+
+}
+
+// emailTaken сообщает, занят ли email без учета регистра пользователем, отличным от exceptID.
+// Вызывается под блокировкой storageUser
+func (rs *RepositoryApp) emailTaken(email string, exceptID int64) bool {
+	for id, user := range rs.storageUser.data {
+		if id != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rs *RepositoryApp) UpdateUserByID(ctx context.Context, userID int64, nickname string, email string) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageUser.mx.Lock()
+	defer rs.storageUser.mx.Unlock()
+
+	user, ok := rs.storageUser.data[userID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	if rs.emailTaken(email, userID) {
+		return app.ErrAlreadyExists
+	}
+
+	prev := *user
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		*user = prev
+	})
+
+	user.Nickname = nickname
+	user.Email = email
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) UpdateUserRole(ctx context.Context, userID int64, role users.Role) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageUser.mx.Lock()
+	defer rs.storageUser.mx.Unlock()
+
+	user, ok := rs.storageUser.data[userID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	prev := *user
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		*user = prev
+	})
+
+	user.Role = role
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) SearchAdByName(ctx context.Context, adName string) (*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	for _, v := range rs.storageAd.data {
+		if v.Published() && v.DeletedAt.IsZero() && strings.Contains(v.Title, adName) {
+			res := *v
+			return &res, nil
+		}
+	}
+
+	return &ads.Ad{}, ErrNotFound
+
+}
+
+func (rs *RepositoryApp) SearchAds(ctx context.Context, query string, limit int) ([]*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Ad{}
+
+	for _, found := range rs.storageAd.index.Search(query) {
+		if len(res) == limit {
+			break
+		}
+
+		v := rs.storageAd.data[found.ID]
+		if !v.Published() || !v.DeletedAt.IsZero() {
+			continue
+		}
+
+		ad := *v
+		res = append(res, &ad)
+	}
+
+	if len(res) == 0 {
+		return res, ErrNotFound
+	}
+
+	return res, nil
+}
+
+func (rs *RepositoryApp) FilterAds(ctx context.Context, filter *app.Filter) ([]*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Ad{}
+
+	for _, v := range rs.storageAd.data {
+		if !filter.Match(v) {
+			continue
+		}
+		if !filter.AfterCursor(v) {
+			continue
+		}
+		ad := *v
+		res = append(res, &ad)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return filter.Less(res[i], res[j])
+	})
+
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+
+	if len(res) == 0 {
+		return res, ErrNotFound
+	}
+
+	return res, nil
+}
+
+func (rs *RepositoryApp) BumpAdVersion(ctx context.Context, adID int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.rememberAd(ctx, ad)
+
+	ad.Version++
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) SetAdDeletedAt(ctx context.Context, adID int64, deletedAt time.Time) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	ad, ok := rs.storageAd.data[adID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.rememberAd(ctx, ad)
+
+	ad.DeletedAt = deletedAt
+	ad.Version++
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) GetDeletedAds(ctx context.Context, before time.Time) ([]*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Ad{}
+
+	for _, v := range rs.storageAd.data {
+		if v.DeletedAt.IsZero() || v.DeletedAt.After(before) {
+			continue
+		}
+		ad := *v
+		res = append(res, &ad)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (rs *RepositoryApp) PurgeAds(ctx context.Context, before time.Time) (int, error) {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	purged := 0
+
+	for id, v := range rs.storageAd.data {
+		if !v.DeletedAt.IsZero() && !v.DeletedAt.After(before) {
+			rs.deleteAd(ctx, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+
+}
+
+func (rs *RepositoryApp) GetAdsByAuthor(ctx context.Context, authorID int64) ([]*ads.Ad, error) {
+	rs.storageAd.mx.RLock()
+	defer rs.storageAd.mx.RUnlock()
+
+	res := []*ads.Ad{}
+
+	for _, v := range rs.storageAd.data {
+		if v.AuthorID != authorID {
+			continue
+		}
+		ad := *v
+		res = append(res, &ad)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (rs *RepositoryApp) DeleteAdsByAuthor(ctx context.Context, authorID int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAd.mx.Lock()
+	defer rs.storageAd.mx.Unlock()
+
+	for id, v := range rs.storageAd.data {
+		if v.AuthorID == authorID {
+			rs.deleteAd(ctx, id)
+		}
+	}
+
+	return nil
+
+}
+
+// deleteAd удаляет объявление с его историей из хранилища и индекса, вызывается под storageAd.mx
+func (rs *RepositoryApp) deleteAd(ctx context.Context, adID int64) {
+	ad := rs.storageAd.data[adID]
+	history, hasHistory := rs.storageAd.transitions[adID]
+	attachments, hasAttachments := rs.storageAd.attachments[adID]
+
+	rs.onRollback(ctx, func() {
+		rs.storageAd.mx.Lock()
+		defer rs.storageAd.mx.Unlock()
+
+		rs.storageAd.data[adID] = ad
+		rs.storageAd.index.Add(adID, ad.Title, ad.Text)
+		if hasHistory {
+			rs.storageAd.transitions[adID] = history
+		}
+		if hasAttachments {
+			rs.storageAd.attachments[adID] = attachments
+		}
+	})
+
+	delete(rs.storageAd.data, adID)
+	delete(rs.storageAd.transitions, adID)
+	delete(rs.storageAd.attachments, adID)
+	rs.storageAd.index.Remove(adID)
+}
+
+func (rs *RepositoryApp) DeleteUser(ctx context.Context, userID int64) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageUser.mx.Lock()
+	defer rs.storageUser.mx.Unlock()
+
+	user, ok := rs.storageUser.data[userID]
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	rs.onRollback(ctx, func() {
+		rs.storageUser.mx.Lock()
+		defer rs.storageUser.mx.Unlock()
+
+		rs.storageUser.data[userID] = user
+	})
+
+	delete(rs.storageUser.data, userID)
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) StoreAuditEntry(ctx context.Context, entry *audit.Entry) error {
+	unlock := rs.writeLock(ctx)
+	defer unlock()
+
+	rs.storageAudit.mx.Lock()
+	defer rs.storageAudit.mx.Unlock()
+
+	entry.ID = rs.auditSeq.Add(1)
+
+	size := len(rs.storageAudit.entries)
+
+	rs.onRollback(ctx, func() {
+		rs.storageAudit.mx.Lock()
+		defer rs.storageAudit.mx.Unlock()
+
+		rs.storageAudit.entries = rs.storageAudit.entries[:size]
+	})
+
+	stored := *entry
+	stored.Changes = append([]audit.Change{}, entry.Changes...)
+	rs.storageAudit.entries = append(rs.storageAudit.entries, stored)
+
+	return nil
+
+}
+
+func (rs *RepositoryApp) AuditEntries(ctx context.Context, filter *audit.Filter) ([]*audit.Entry, error) {
+	rs.storageAudit.mx.RLock()
+	defer rs.storageAudit.mx.RUnlock()
+
+	res := []*audit.Entry{}
+
+	for i := len(rs.storageAudit.entries) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(res) == filter.Limit {
+			break
+		}
+
+		entry := rs.storageAudit.entries[i]
+
+		if !filter.Match(&entry) {
+			continue
+		}
+
+		entry.Changes = append([]audit.Change{}, entry.Changes...)
+		res = append(res, &entry)
+	}
+
+	return res, nil
+
+}
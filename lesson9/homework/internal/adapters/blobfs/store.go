@@ -0,0 +1,115 @@
+package blobfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"homework9/internal/app"
+)
+
+var (
+	ErrNotFound   = fmt.Errorf("not found")
+	ErrInvalidKey = fmt.Errorf("invalid blob key")
+)
+
+// Store хранит каждое содержимое в отдельном файле каталога dir под именем ключа
+type Store struct {
+	dir string
+}
+
+func New(dir string) (app.BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("can't create blob dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// path не дает ключу выйти за пределы каталога
+func (s *Store) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// Put пишет содержимое во временный файл и переименовывает его,
+// поэтому читатели никогда не видят недописанное содержимое
+func (s *Store) Put(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("can't create blob file: %w", err)
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("can't sync blob file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("can't close blob file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("can't rename blob file: %w", err)
+	}
+
+	committed = true
+
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't open blob file: %w", err)
+	}
+
+	return f, nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("can't remove blob file: %w", err)
+	}
+
+	return nil
+}
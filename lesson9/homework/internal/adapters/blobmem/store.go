@@ -0,0 +1,71 @@
+package blobmem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"homework9/internal/app"
+)
+
+var ErrNotFound = fmt.Errorf("not found")
+
+// Store хранит содержимое в памяти процесса, используется в тестах и вместе с adrepo
+type Store struct {
+	mx   *sync.RWMutex
+	data map[string][]byte
+}
+
+func New() app.BlobStore {
+	return &Store{mx: &sync.RWMutex{}, data: make(map[string][]byte)}
+}
+
+func (s *Store) Put(ctx context.Context, key string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.data[key] = content
+
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	content, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// содержимое не меняется после Put, поэтому копировать его не нужно
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if _, ok := s.data[key]; !ok {
+		return ErrNotFound
+	}
+
+	delete(s.data, key)
+
+	return nil
+}
+
+// Len возвращает количество сохраненных ключей, тесты проверяют по нему, что содержимое не осталось
+func (s *Store) Len() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return len(s.data)
+}
@@ -0,0 +1,722 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"homework9/internal/ads"
+	"homework9/internal/app"
+	"homework9/internal/audit"
+	"homework9/internal/search"
+	"homework9/internal/users"
+)
+
+var ErrNotFound = fmt.Errorf("not found")
+
+type RepositoryPG struct {
+	pool *pgxpool.Pool
+}
+
+func New(pool *pgxpool.Pool) app.Repository {
+	return &RepositoryPG{pool: pool}
+}
+
+const adColumns = `id, title, text, author_id, state, rejection_reason, creation_date, update_date, deleted_at, version,
+	price, currency, category, region, city`
+
+func scanAd(row pgx.Row) (*ads.Ad, error) {
+	ad := &ads.Ad{}
+
+	var updateDate, deletedAt *time.Time
+
+	if err := row.Scan(&ad.ID, &ad.Title, &ad.Text, &ad.AuthorID, &ad.State, &ad.RejectionReason, &ad.CreationDate, &updateDate, &deletedAt, &ad.Version,
+		&ad.Price, &ad.Currency, &ad.Category, &ad.Location.Region, &ad.Location.City); err != nil {
+		return &ads.Ad{}, err
+	}
+
+	ad.CreationDate = ad.CreationDate.UTC()
+
+	if updateDate != nil {
+		ad.UpdateDate = updateDate.UTC()
+	}
+
+	if deletedAt != nil {
+		ad.DeletedAt = deletedAt.UTC()
+	}
+
+	return ad, nil
+}
+
+// nullTime переводит нулевое время в NULL, чтобы не хранить в базе 0001-01-01
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+const getAdByIDQuery = `SELECT ` + adColumns + ` FROM ads WHERE id = $1`
+
+// GetAdByID внутри транзакции блокирует строку объявления до конца транзакции,
+// чтобы конкурентные изменения одного объявления выполнялись последовательно
+func (r *RepositoryPG) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, error) {
+	q := getAdByIDQuery
+	if _, ok := txFromContext(ctx); ok {
+		q += ` FOR UPDATE`
+	}
+
+	ad, err := scanAd(r.conn(ctx).QueryRow(ctx, q, adID))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &ads.Ad{}, fmt.Errorf("can't scan ad: %w", err)
+	}
+
+	attachments, err := r.GetAttachments(ctx, adID)
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	ad.Attachments = make([]ads.Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		ad.Attachments = append(ad.Attachments, *attachment)
+	}
+
+	return ad, nil
+}
+
+const storeAdQuery = `INSERT INTO ads (title, text, author_id, state, creation_date, update_date, version, price, currency, category, region, city)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+
+func (r *RepositoryPG) StoreAd(ctx context.Context, ad *ads.Ad) (int64, error) {
+	var adID int64
+
+	err := r.conn(ctx).QueryRow(ctx, storeAdQuery, ad.Title, ad.Text, ad.AuthorID, ad.State, ad.CreationDate, nullTime(ad.UpdateDate), ad.Version,
+		ad.Price, ad.Currency, ad.Category, ad.Location.Region, ad.Location.City).Scan(&adID)
+
+	if err != nil {
+		return 0, fmt.Errorf("can't insert ad: %w", err)
+	}
+
+	return adID, nil
+}
+
+// updateAdQuery проверяет версию в том же запросе, что и меняет объявление,
+// поэтому параллельное изменение между чтением и записью невозможно
+const updateAdQuery = `UPDATE ads SET title = $2, text = $3, update_date = $4, version = version + 1,
+	price = $6, currency = $7, category = $8, region = $9, city = $10
+	WHERE id = $1 AND ($5::bigint = 0 OR version = $5)`
+
+const adExistsQuery = `SELECT EXISTS (SELECT 1 FROM ads WHERE id = $1)`
+
+func (r *RepositoryPG) UpdateADByID(ctx context.Context, adID int64, title string, text string, details ads.Details, version int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateAdQuery, adID, title, text, time.Now().UTC(), version,
+		details.Price, details.Currency, details.Category, details.Location.Region, details.Location.City)
+
+	if err != nil {
+		return fmt.Errorf("can't update ad: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+
+	if err := r.conn(ctx).QueryRow(ctx, adExistsQuery, adID).Scan(&exists); err != nil {
+		return fmt.Errorf("can't check ad exists: %w", err)
+	}
+
+	if exists {
+		return app.ErrVersionMismatch
+	}
+
+	return ErrNotFound
+}
+
+const updateAdStateQuery = `UPDATE ads SET state = $2, rejection_reason = $3, update_date = $4, version = version + 1 WHERE id = $1`
+
+func (r *RepositoryPG) UpdateAdState(ctx context.Context, adID int64, state ads.State, reason string) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateAdStateQuery, adID, state, reason, time.Now().UTC())
+
+	if err != nil {
+		return fmt.Errorf("can't update ad state: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const storeTransitionQuery = `INSERT INTO ad_transitions (ad_id, from_state, to_state, actor_id, reason, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+func (r *RepositoryPG) StoreTransition(ctx context.Context, transition *ads.Transition) error {
+	_, err := r.conn(ctx).Exec(ctx, storeTransitionQuery, transition.AdID, transition.From, transition.To, transition.ActorID, transition.Reason, transition.Date)
+
+	if err != nil {
+		return fmt.Errorf("can't insert ad transition: %w", err)
+	}
+
+	return nil
+}
+
+const getTransitionsQuery = `SELECT ad_id, from_state, to_state, actor_id, reason, created_at
+	FROM ad_transitions WHERE ad_id = $1 ORDER BY id`
+
+func (r *RepositoryPG) GetTransitions(ctx context.Context, adID int64) ([]*ads.Transition, error) {
+	rows, err := r.conn(ctx).Query(ctx, getTransitionsQuery, adID)
+	if err != nil {
+		return []*ads.Transition{}, fmt.Errorf("can't select ad transitions: %w", err)
+	}
+
+	defer rows.Close()
+
+	res := []*ads.Transition{}
+
+	for rows.Next() {
+		transition := &ads.Transition{}
+
+		if err := rows.Scan(&transition.AdID, &transition.From, &transition.To, &transition.ActorID, &transition.Reason, &transition.Date); err != nil {
+			return []*ads.Transition{}, fmt.Errorf("can't scan ad transition: %w", err)
+		}
+
+		transition.Date = transition.Date.UTC()
+		res = append(res, transition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []*ads.Transition{}, fmt.Errorf("can't select ad transitions: %w", err)
+	}
+
+	return res, nil
+}
+
+const getUserByIDQuery = `SELECT id, nickname, email, password_hash, role FROM users WHERE id = $1`
+
+func (r *RepositoryPG) GetUserByID(ctx context.Context, userID int64) (*users.User, error) {
+	user := &users.User{}
+
+	err := r.conn(ctx).QueryRow(ctx, getUserByIDQuery, userID).Scan(&user.ID, &user.Nickname, &user.Email, &user.PasswordHash, &user.Role)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &users.User{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &users.User{}, fmt.Errorf("can't scan user: %w", err)
+	}
+
+	return user, nil
+}
+
+const getUserByEmailQuery = `SELECT id, nickname, email, password_hash, role FROM users WHERE lower(email) = lower($1)`
+
+func (r *RepositoryPG) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	user := &users.User{}
+
+	err := r.conn(ctx).QueryRow(ctx, getUserByEmailQuery, email).Scan(&user.ID, &user.Nickname, &user.Email, &user.PasswordHash, &user.Role)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &users.User{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &users.User{}, fmt.Errorf("can't scan user: %w", err)
+	}
+
+	return user, nil
+}
+
+const storeUserQuery = `INSERT INTO users (nickname, email, password_hash, role) VALUES ($1, $2, $3, $4) RETURNING id`
+
+func (r *RepositoryPG) StoreUser(ctx context.Context, user *users.User) (int64, error) {
+	var userID int64
+
+	err := r.conn(ctx).QueryRow(ctx, storeUserQuery, user.Nickname, user.Email, user.PasswordHash, user.Role).Scan(&userID)
+
+	if isUniqueViolation(err) {
+		return 0, app.ErrAlreadyExists
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("can't insert user: %w", err)
+	}
+
+	return userID, nil
+}
+
+const updateUserQuery = `UPDATE users SET nickname = $2, email = $3 WHERE id = $1`
+
+func (r *RepositoryPG) UpdateUserByID(ctx context.Context, userID int64, nickname string, email string) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateUserQuery, userID, nickname, email)
+
+	if isUniqueViolation(err) {
+		return app.ErrAlreadyExists
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't update user: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// isUniqueViolation сообщает, что запрос нарушил уникальный индекс, например users_email_lower_idx
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+const updateUserRoleQuery = `UPDATE users SET role = $2 WHERE id = $1`
+
+func (r *RepositoryPG) UpdateUserRole(ctx context.Context, userID int64, role users.Role) error {
+	tag, err := r.conn(ctx).Exec(ctx, updateUserRoleQuery, userID, role)
+
+	if err != nil {
+		return fmt.Errorf("can't update user role: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const searchAdByNameQuery = `SELECT ` + adColumns + ` FROM ads WHERE state = 'published' AND deleted_at IS NULL AND strpos(title, $1) > 0 ORDER BY id LIMIT 1`
+
+func (r *RepositoryPG) SearchAdByName(ctx context.Context, adName string) (*ads.Ad, error) {
+	ad, err := scanAd(r.conn(ctx).QueryRow(ctx, searchAdByNameQuery, adName))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &ads.Ad{}, fmt.Errorf("can't scan ad: %w", err)
+	}
+
+	return ad, nil
+}
+
+const searchAdsQuery = `SELECT ` + adColumns + ` FROM ads, to_tsquery('simple', $1) query
+	WHERE state = 'published' AND deleted_at IS NULL AND search @@ query
+	ORDER BY ts_rank(search, query) DESC, id
+	LIMIT $2`
+
+func (r *RepositoryPG) SearchAds(ctx context.Context, query string, limit int) ([]*ads.Ad, error) {
+	return r.selectAds(ctx, searchAdsQuery, search.PrefixQuery(query), limit)
+}
+
+// sortColumns задает выражения сортировки, совпадающие с порядком app.Filter.Less:
+// объявление без обновлений имеет нулевую дату, заголовки сравниваются побайтово
+var sortColumns = map[app.SortField]string{
+	app.SortByCreationDate: `creation_date`,
+	app.SortByUpdateDate:   `COALESCE(update_date, '0001-01-01 00:00:00+00')`,
+	app.SortByTitle:        `title COLLATE "C"`,
+}
+
+func (r *RepositoryPG) FilterAds(ctx context.Context, filter *app.Filter) ([]*ads.Ad, error) {
+	conds := []string{"deleted_at IS NULL"}
+	args := []any{}
+
+	switch filter.Status {
+	case app.StatusPublished:
+		conds = append(conds, "state = 'published'")
+	case app.StatusUnpublished:
+		conds = append(conds, "state <> 'published'")
+	case app.StatusAll:
+	default:
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("state = $%d", len(args)))
+	}
+
+	if filter.AuthorID != -1 {
+		args = append(args, filter.AuthorID)
+		conds = append(conds, fmt.Sprintf("author_id = $%d", len(args)))
+	}
+	if !filter.PublishedAfter.IsZero() {
+		args = append(args, filter.PublishedAfter)
+		conds = append(conds, fmt.Sprintf("creation_date >= $%d", len(args)))
+	}
+	if !filter.PublishedBefore.IsZero() {
+		args = append(args, filter.PublishedBefore)
+		conds = append(conds, fmt.Sprintf("creation_date <= $%d", len(args)))
+	}
+	if filter.TitleContains != "" {
+		args = append(args, filter.TitleContains)
+		conds = append(conds, fmt.Sprintf("strpos(lower(title), lower($%d)) > 0", len(args)))
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		args = append(args, filter.UpdatedAfter)
+		conds = append(conds, fmt.Sprintf("update_date >= $%d", len(args)))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		args = append(args, filter.UpdatedBefore)
+		conds = append(conds, fmt.Sprintf("update_date <= $%d", len(args)))
+	}
+	if filter.MinPrice != -1 {
+		args = append(args, filter.MinPrice)
+		conds = append(conds, fmt.Sprintf("price >= $%d", len(args)))
+	}
+	if filter.MaxPrice != -1 {
+		args = append(args, filter.MaxPrice)
+		conds = append(conds, fmt.Sprintf("price <= $%d", len(args)))
+	}
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		conds = append(conds, fmt.Sprintf("currency = $%d", len(args)))
+	}
+	if filter.Category != "" {
+		// путь категории проверен приложением и не содержит символов шаблона LIKE
+		args = append(args, filter.Category)
+		conds = append(conds, fmt.Sprintf("(category = $%d OR category LIKE $%d || '/%%')", len(args), len(args)))
+	}
+	if filter.Region != "" {
+		args = append(args, filter.Region)
+		conds = append(conds, fmt.Sprintf("lower(region) = lower($%d)", len(args)))
+	}
+	if filter.City != "" {
+		args = append(args, filter.City)
+		conds = append(conds, fmt.Sprintf("lower(city) = lower($%d)", len(args)))
+	}
+
+	sortColumn := sortColumns[filter.SortBy]
+	direction, cmp := "ASC", ">"
+	if filter.Descending {
+		direction, cmp = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		var key any = filter.After.Date
+		if filter.SortBy == app.SortByTitle {
+			key = filter.After.Title
+		}
+		args = append(args, key, filter.After.ID)
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d, $%d)", sortColumn, cmp, len(args)-1, len(args)))
+	}
+
+	q := `SELECT ` + adColumns + ` FROM ads WHERE ` + strings.Join(conds, " AND ") +
+		fmt.Sprintf(" ORDER BY %s %s, id %s", sortColumn, direction, direction)
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		q += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return r.selectAds(ctx, q, args...)
+}
+
+const bumpAdVersionQuery = `UPDATE ads SET version = version + 1 WHERE id = $1`
+
+func (r *RepositoryPG) BumpAdVersion(ctx context.Context, adID int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, bumpAdVersionQuery, adID)
+
+	if err != nil {
+		return fmt.Errorf("can't update ad version: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const setAdDeletedAtQuery = `UPDATE ads SET deleted_at = $2, version = version + 1 WHERE id = $1`
+
+func (r *RepositoryPG) SetAdDeletedAt(ctx context.Context, adID int64, deletedAt time.Time) error {
+	tag, err := r.conn(ctx).Exec(ctx, setAdDeletedAtQuery, adID, nullTime(deletedAt))
+
+	if err != nil {
+		return fmt.Errorf("can't update ad deleted_at: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const getDeletedAdsQuery = `SELECT ` + adColumns + ` FROM ads WHERE deleted_at <= $1 ORDER BY id`
+
+// GetDeletedAds внутри транзакции блокирует найденные объявления, чтобы их не восстановили до PurgeAds
+func (r *RepositoryPG) GetDeletedAds(ctx context.Context, before time.Time) ([]*ads.Ad, error) {
+	return r.selectAdsForUpdate(ctx, getDeletedAdsQuery, before)
+}
+
+const purgeAdsQuery = `DELETE FROM ads WHERE deleted_at <= $1`
+
+func (r *RepositoryPG) PurgeAds(ctx context.Context, before time.Time) (int, error) {
+	tag, err := r.conn(ctx).Exec(ctx, purgeAdsQuery, before)
+
+	if err != nil {
+		return 0, fmt.Errorf("can't purge ads: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+const getAdsByAuthorQuery = `SELECT ` + adColumns + ` FROM ads WHERE author_id = $1 ORDER BY id`
+
+// GetAdsByAuthor внутри транзакции блокирует найденные объявления, чтобы к ним не добавили вложений до удаления
+func (r *RepositoryPG) GetAdsByAuthor(ctx context.Context, authorID int64) ([]*ads.Ad, error) {
+	return r.selectAdsForUpdate(ctx, getAdsByAuthorQuery, authorID)
+}
+
+const deleteAdsByAuthorQuery = `DELETE FROM ads WHERE author_id = $1`
+
+func (r *RepositoryPG) DeleteAdsByAuthor(ctx context.Context, authorID int64) error {
+	if _, err := r.conn(ctx).Exec(ctx, deleteAdsByAuthorQuery, authorID); err != nil {
+		return fmt.Errorf("can't delete ads: %w", err)
+	}
+
+	return nil
+}
+
+const deleteUserQuery = `DELETE FROM users WHERE id = $1`
+
+func (r *RepositoryPG) DeleteUser(ctx context.Context, userID int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, deleteUserQuery, userID)
+
+	if err != nil {
+		return fmt.Errorf("can't delete user: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// selectAdsForUpdate, в отличие от selectAds, внутри транзакции блокирует найденные объявления,
+// а пустой результат ошибкой не считает
+func (r *RepositoryPG) selectAdsForUpdate(ctx context.Context, q string, args ...any) ([]*ads.Ad, error) {
+	if _, ok := txFromContext(ctx); ok {
+		q += ` FOR UPDATE`
+	}
+
+	res, err := r.selectAds(ctx, q, args...)
+
+	if errors.Is(err, ErrNotFound) {
+		return []*ads.Ad{}, nil
+	}
+
+	return res, err
+}
+
+func (r *RepositoryPG) selectAds(ctx context.Context, q string, args ...any) ([]*ads.Ad, error) {
+	rows, err := r.conn(ctx).Query(ctx, q, args...)
+	if err != nil {
+		return []*ads.Ad{}, fmt.Errorf("can't select ads: %w", err)
+	}
+
+	defer rows.Close()
+
+	res := []*ads.Ad{}
+
+	for rows.Next() {
+		ad, err := scanAd(rows)
+		if err != nil {
+			return []*ads.Ad{}, fmt.Errorf("can't scan ad: %w", err)
+		}
+
+		res = append(res, ad)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []*ads.Ad{}, fmt.Errorf("can't select ads: %w", err)
+	}
+
+	if len(res) == 0 {
+		return res, ErrNotFound
+	}
+
+	return res, nil
+}
+
+const storeAuditEntryQuery = `INSERT INTO audit_log (actor_id, action, resource, resource_id, changes, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+func (r *RepositoryPG) StoreAuditEntry(ctx context.Context, entry *audit.Entry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return fmt.Errorf("can't encode audit changes: %w", err)
+	}
+
+	err = r.conn(ctx).QueryRow(ctx, storeAuditEntryQuery, entry.ActorID, entry.Action, entry.Resource, entry.ResourceID, changes, entry.Date).Scan(&entry.ID)
+
+	if err != nil {
+		return fmt.Errorf("can't insert audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RepositoryPG) AuditEntries(ctx context.Context, filter *audit.Filter) ([]*audit.Entry, error) {
+	conds := []string{"true"}
+	args := []any{}
+
+	if filter.Resource != "" {
+		args = append(args, filter.Resource)
+		conds = append(conds, fmt.Sprintf("resource = $%d", len(args)))
+	}
+	if filter.ResourceID != -1 {
+		args = append(args, filter.ResourceID)
+		conds = append(conds, fmt.Sprintf("resource_id = $%d", len(args)))
+	}
+	if filter.BeforeID != -1 {
+		args = append(args, filter.BeforeID)
+		conds = append(conds, fmt.Sprintf("id < $%d", len(args)))
+	}
+
+	query := `SELECT id, actor_id, action, resource, resource_id, changes, created_at FROM audit_log WHERE ` +
+		strings.Join(conds, " AND ") + ` ORDER BY id DESC`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return []*audit.Entry{}, fmt.Errorf("can't select audit entries: %w", err)
+	}
+
+	defer rows.Close()
+
+	res := []*audit.Entry{}
+
+	for rows.Next() {
+		entry := &audit.Entry{}
+		var changes []byte
+
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.Resource, &entry.ResourceID, &changes, &entry.Date); err != nil {
+			return []*audit.Entry{}, fmt.Errorf("can't scan audit entry: %w", err)
+		}
+
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return []*audit.Entry{}, fmt.Errorf("can't decode audit changes: %w", err)
+		}
+
+		entry.Date = entry.Date.UTC()
+		res = append(res, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []*audit.Entry{}, fmt.Errorf("can't select audit entries: %w", err)
+	}
+
+	return res, nil
+}
+
+const attachmentColumns = `id, ad_id, name, content_type, size, blob_key, created_at`
+
+func scanAttachment(row pgx.Row) (*ads.Attachment, error) {
+	attachment := &ads.Attachment{}
+
+	if err := row.Scan(&attachment.ID, &attachment.AdID, &attachment.Name, &attachment.ContentType, &attachment.Size, &attachment.Key, &attachment.CreationDate); err != nil {
+		return &ads.Attachment{}, err
+	}
+
+	attachment.CreationDate = attachment.CreationDate.UTC()
+
+	return attachment, nil
+}
+
+const storeAttachmentQuery = `INSERT INTO ad_attachments (ad_id, name, content_type, size, blob_key, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+func (r *RepositoryPG) StoreAttachment(ctx context.Context, attachment *ads.Attachment) (int64, error) {
+	var attachmentID int64
+
+	err := r.conn(ctx).QueryRow(ctx, storeAttachmentQuery, attachment.AdID, attachment.Name, attachment.ContentType, attachment.Size, attachment.Key, attachment.CreationDate).Scan(&attachmentID)
+
+	if err != nil {
+		return 0, fmt.Errorf("can't insert ad attachment: %w", err)
+	}
+
+	return attachmentID, nil
+}
+
+const getAttachmentQuery = `SELECT ` + attachmentColumns + ` FROM ad_attachments WHERE ad_id = $1 AND id = $2`
+
+func (r *RepositoryPG) GetAttachment(ctx context.Context, adID int64, attachmentID int64) (*ads.Attachment, error) {
+	attachment, err := scanAttachment(r.conn(ctx).QueryRow(ctx, getAttachmentQuery, adID, attachmentID))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &ads.Attachment{}, ErrNotFound
+	}
+
+	if err != nil {
+		return &ads.Attachment{}, fmt.Errorf("can't scan ad attachment: %w", err)
+	}
+
+	return attachment, nil
+}
+
+const getAttachmentsQuery = `SELECT ` + attachmentColumns + ` FROM ad_attachments WHERE ad_id = $1 ORDER BY id`
+
+func (r *RepositoryPG) GetAttachments(ctx context.Context, adID int64) ([]*ads.Attachment, error) {
+	rows, err := r.conn(ctx).Query(ctx, getAttachmentsQuery, adID)
+	if err != nil {
+		return []*ads.Attachment{}, fmt.Errorf("can't select ad attachments: %w", err)
+	}
+
+	defer rows.Close()
+
+	res := []*ads.Attachment{}
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+
+		if err != nil {
+			return []*ads.Attachment{}, fmt.Errorf("can't scan ad attachment: %w", err)
+		}
+
+		res = append(res, attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []*ads.Attachment{}, fmt.Errorf("can't select ad attachments: %w", err)
+	}
+
+	return res, nil
+}
+
+const deleteAttachmentQuery = `DELETE FROM ad_attachments WHERE ad_id = $1 AND id = $2`
+
+func (r *RepositoryPG) DeleteAttachment(ctx context.Context, adID int64, attachmentID int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, deleteAttachmentQuery, adID, attachmentID)
+
+	if err != nil {
+		return fmt.Errorf("can't delete ad attachment: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -1,9 +1,69 @@
 package ads
 
+import "time"
+
+// State - этап жизненного цикла объявления, допустимые переходы между этапами задает приложение
+type State string
+
+const (
+	StateDraft     State = "draft"
+	StatePending   State = "pending"
+	StatePublished State = "published"
+	StateRejected  State = "rejected"
+	StateArchived  State = "archived"
+)
+
+func (s State) Valid() bool {
+	switch s {
+	case StateDraft, StatePending, StatePublished, StateRejected, StateArchived:
+		return true
+	}
+	return false
+}
+
 type Ad struct {
-	ID        int64
-	Title     string
-	Text      string
-	AuthorID  int64
-	Published bool
+	ID       int64
+	Title    string `validate:"max:100"`
+	Text     string `validate:"max:500"`
+	AuthorID int64
+	State    State
+	// RejectionReason - причина отклонения, заполнена только в состоянии StateRejected
+	RejectionReason string
+	CreationDate    time.Time
+	UpdateDate      time.Time
+	DeletedAt       time.Time // нулевое время - объявление не удалено
+	// Version увеличивается хранилищем при каждом изменении объявления, новое объявление имеет версию 1
+	Version int64
+	// Details проверяются отдельно: validator не заходит во вложенные структуры
+	Details
+	// Attachments - вложения в порядке загрузки, заполняются только при получении объявления по ID
+	Attachments []Attachment
+}
+
+// Published сообщает, видно ли объявление всем пользователям
+func (ad *Ad) Published() bool {
+	return ad.State == StatePublished
+}
+
+// Transition - запись истории смены состояния объявления
+type Transition struct {
+	AdID    int64
+	From    State
+	To      State
+	ActorID int64
+	Reason  string
+	Date    time.Time
+}
+
+// Attachment - описание вложения объявления, содержимое хранится отдельно под ключом Key
+type Attachment struct {
+	ID   int64
+	AdID int64
+	// Name - имя файла, под которым вложение было загружено
+	Name string
+	// ContentType определяется по содержимому, а не по заявленному клиентом типу
+	ContentType  string
+	Size         int64
+	Key          string
+	CreationDate time.Time
 }
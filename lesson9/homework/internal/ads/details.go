@@ -0,0 +1,92 @@
+package ads
+
+import "strings"
+
+// DefaultCurrency - валюта цены, если автор ее не указал
+const DefaultCurrency = "RUB"
+
+// Currencies - коды ISO 4217, в которых можно указать цену. Список совпадает с тегом validate поля Details.Currency
+var Currencies = []string{"RUB", "USD", "EUR", "GBP", "CNY", "KZT", "BYN"}
+
+// Details - структурированные поля объявления
+type Details struct {
+	// Price - цена в минимальных единицах валюты (копейках, центах), 0 - цена не указана
+	Price    int64  `validate:"min:0"`
+	Currency string `validate:"in:RUB,USD,EUR,GBP,CNY,KZT,BYN"`
+	// Category - путь категории из Categories, пустой - без категории
+	Category string
+	Location Location
+}
+
+// Location - место продажи. Регион и город указываются вместе или не указываются вовсе,
+// поэтому пустые значения validator не пропускает, а нулевое место приложение не проверяет
+type Location struct {
+	Region string `validate:"max:100"`
+	City   string `validate:"max:100"`
+}
+
+func (l Location) IsZero() bool {
+	return l.Region == "" && l.City == ""
+}
+
+// CategorySeparator разделяет имена категорий в пути от корня дерева
+const CategorySeparator = "/"
+
+// Categories - дерево категорий в виде путей. Родитель всегда идет перед потомками,
+// имена состоят из строчных латинских букв, поэтому путь безопасно использовать в шаблоне LIKE
+var Categories = []string{
+	"electronics",
+	"electronics/phones",
+	"electronics/computers",
+	"electronics/audio",
+	"electronics/photo",
+	"transport",
+	"transport/cars",
+	"transport/motorcycles",
+	"transport/parts",
+	"realty",
+	"realty/apartments",
+	"realty/houses",
+	"realty/rooms",
+	"home",
+	"home/furniture",
+	"home/appliances",
+	"home/garden",
+	"clothes",
+	"clothes/men",
+	"clothes/women",
+	"clothes/kids",
+	"hobby",
+	"hobby/books",
+	"hobby/sport",
+	"hobby/music",
+	"animals",
+	"services",
+	"jobs",
+}
+
+var categories = func() map[string]bool {
+	set := make(map[string]bool, len(Categories))
+	for _, category := range Categories {
+		set[category] = true
+	}
+	return set
+}()
+
+func ValidCategory(category string) bool {
+	return categories[category]
+}
+
+func ValidCurrency(currency string) bool {
+	for _, c := range Currencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// InCategory сообщает, что category - это root или одна из ее подкатегорий
+func InCategory(category string, root string) bool {
+	return category == root || strings.HasPrefix(category, root+CategorySeparator)
+}
@@ -0,0 +1,1249 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/InfinityMeta/validator"
+
+	"homework9/internal/ads"
+	"homework9/internal/audit"
+	"homework9/internal/auth"
+	"homework9/internal/events"
+	"homework9/internal/search"
+	"homework9/internal/users"
+)
+
+var (
+	ErrStatusForbidden = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrNotValid        = errors.New("not valid")
+	// ErrUnauthenticated - запрос без аутентифицированного пользователя или с неверными учетными данными
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrAlreadyExists - нарушена уникальность, например email уже занят другим пользователем
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalidTransition - объявление нельзя перевести в запрошенное состояние из текущего
+	ErrInvalidTransition = errors.New("invalid transition")
+	// ErrVersionMismatch - объявление изменилось с тех пор, как клиент прочитал версию, на которую опирается изменение
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+const DefaultSearchLimit = 20
+
+// DefaultAuditLimit - размер страницы журнала аудита по умолчанию
+const DefaultAuditLimit = 50
+
+const (
+	DefaultRestoreWindow = 7 * 24 * time.Hour
+	DefaultRetention     = 30 * 24 * time.Hour
+)
+
+// Методы, изменяющие объявления и пользователей, действуют от имени пользователя,
+// сохраненного в контексте через auth.WithUserID, и возвращают ErrUnauthenticated, если его нет.
+// Доступ к чужим объявлениям и учетным записям определяет политика (см. Can)
+type App interface {
+	// CreateAd создает черновик объявления, пустая валюта заменяется на ads.DefaultCurrency
+	CreateAd(context.Context, string, string, ads.Details) (*ads.Ad, error)
+	// CreateUser регистрирует пользователя с паролем, аутентификация для этого не нужна
+	CreateUser(context.Context, string, string, string) (*users.User, error)
+	// TransitionAd переводит объявление в новое состояние, если переход допустим и разрешен пользователю запроса.
+	// При отклонении нужна причина, она видна автору
+	TransitionAd(context.Context, int64, ads.State, string) (*ads.Ad, error)
+	// AdTransitions возвращает историю переходов объявления от старых к новым, доступна автору и модераторам
+	AdTransitions(context.Context, int64) ([]*ads.Transition, error)
+	// UpdateAd меняет заголовок и текст объявления. Если ожидаемая версия не 0 и объявление
+	// с тех пор изменилось, возвращает ErrVersionMismatch и ничего не меняет
+	UpdateAd(context.Context, int64, string, string, int64) (*ads.Ad, error)
+	// PatchAd меняет только переданные поля объявления, проверка версии как в UpdateAd
+	PatchAd(context.Context, int64, AdPatch, int64) (*ads.Ad, error)
+	UpdateUser(context.Context, int64, string, string) (*users.User, error)
+	// PatchUser меняет только переданные поля пользователя
+	PatchUser(context.Context, int64, UserPatch) (*users.User, error)
+	GetUserByID(context.Context, int64) (*users.User, error)
+	CheckUserExists(context.Context, int64) bool
+	GetAdByID(context.Context, int64) (*ads.Ad, error)
+	SearchAdByName(context.Context, string) (*ads.Ad, error)
+	SearchAds(context.Context, string, int) ([]*ads.Ad, error)
+	FilterAds(context.Context, ...FilterOption) ([]*ads.Ad, string, error)
+	// DeleteAd помечает объявление удаленным, автор может восстановить его в течение окна восстановления
+	DeleteAd(context.Context, int64) (*ads.Ad, error)
+	RestoreAd(context.Context, int64) (*ads.Ad, error)
+	// PurgeDeletedAds окончательно удаляет объявления, удаленные раньше срока хранения, и возвращает их количество
+	PurgeDeletedAds(context.Context) (int, error)
+	// DeleteUser удаляет пользователя вместе со всеми его объявлениями
+	DeleteUser(context.Context, int64) (*users.User, error)
+	// SetUserRole назначает пользователю роль, доступно только администраторам
+	SetUserRole(context.Context, int64, users.Role) (*users.User, error)
+	// WatchAds подписывает на изменения объявлений, подходящих под фильтр до или после изменения.
+	// Если объявление подходило под фильтр только до изменения, событие содержит лишь его ID.
+	// Подписка закрывается при отмене контекста
+	WatchAds(context.Context, ...FilterOption) (*events.Subscription, error)
+	// Login проверяет пароль пользователя и выдает токен доступа
+	Login(context.Context, int64, string) (auth.Token, error)
+	// LoginByEmail - то же, что Login, но пользователь ищется по email без учета регистра
+	LoginByEmail(context.Context, string, string) (auth.Token, error)
+	// Authenticate возвращает пользователя, которому выдан действующий токен
+	Authenticate(context.Context, string) (*users.User, error)
+	// AdHistory возвращает журнал изменений объявления, в том числе удаленного, от старых записей к новым.
+	// Доступен автору и модераторам
+	AdHistory(context.Context, int64) ([]*audit.Entry, error)
+	// AuditLog возвращает страницу журнала изменений всего сервиса от новых записей к старым
+	// и курсор следующей страницы, доступен администраторам
+	AuditLog(context.Context, int, string) ([]*audit.Entry, string, error)
+	// AddAttachment добавляет вложение в конец списка вложений объявления, права те же, что на изменение объявления.
+	// Тип содержимого определяется по первым байтам и должен входить в AttachmentTypes
+	AddAttachment(context.Context, int64, string, io.Reader) (*ads.Attachment, error)
+	// OpenAttachment возвращает описание и содержимое вложения, вызывающий закрывает содержимое
+	OpenAttachment(context.Context, int64, int64) (*ads.Attachment, io.ReadCloser, error)
+	// DeleteAttachment удаляет вложение и возвращает объявление с оставшимися вложениями
+	DeleteAttachment(context.Context, int64, int64) (*ads.Ad, error)
+}
+
+type Repository interface {
+	// TODO: реализовать
+	// StoreAd и StoreUser сохраняют сущность под новым уникальным ID, выданным репозиторием, и возвращают его
+	StoreAd(context.Context, *ads.Ad) (int64, error)
+	// StoreUser и UpdateUserByID возвращают ErrAlreadyExists, если email без учета регистра занят другим пользователем
+	StoreUser(context.Context, *users.User) (int64, error)
+	// GetAdByID возвращает объявление, в том числе помеченное удаленным
+	GetAdByID(context.Context, int64) (*ads.Ad, error)
+	GetUserByID(context.Context, int64) (*users.User, error)
+	// GetUserByEmail ищет пользователя по email без учета регистра
+	GetUserByEmail(context.Context, string) (*users.User, error)
+	// UpdateAdState меняет состояние объявления и причину отклонения
+	UpdateAdState(context.Context, int64, ads.State, string) error
+	// StoreTransition добавляет запись в историю переходов объявления
+	StoreTransition(context.Context, *ads.Transition) error
+	// GetTransitions возвращает историю переходов объявления в порядке добавления,
+	// история удаляется вместе с объявлением
+	GetTransitions(context.Context, int64) ([]*ads.Transition, error)
+	// UpdateADByID меняет заголовок, текст и структурированные поля, если версия объявления совпадает
+	// с ожидаемой (0 - любая), иначе возвращает ErrVersionMismatch. Проверка и изменение выполняются атомарно
+	UpdateADByID(context.Context, int64, string, string, ads.Details, int64) error
+	UpdateUserByID(context.Context, int64, string, string) error
+	UpdateUserRole(context.Context, int64, users.Role) error
+	// SearchAdByName, SearchAds и FilterAds не возвращают удаленные объявления
+	// SearchAdByName возвращает первое опубликованное объявление, в заголовке которого есть подстрока
+	SearchAdByName(context.Context, string) (*ads.Ad, error)
+	// SearchAds ищет опубликованные объявления по словам запроса в заголовке и тексте,
+	// возвращает не более limit объявлений по убыванию релевантности
+	SearchAds(context.Context, string, int) ([]*ads.Ad, error)
+	// FilterAds возвращает объявления в порядке сортировки фильтра, начиная после filter.After,
+	// не более filter.Limit штук (0 - без ограничения)
+	FilterAds(context.Context, *Filter) ([]*ads.Ad, error)
+	// BumpAdVersion увеличивает версию объявления, не меняя его полей, например при изменении вложений
+	BumpAdVersion(context.Context, int64) error
+	// SetAdDeletedAt помечает объявление удаленным в момент deletedAt, нулевое время снимает пометку
+	SetAdDeletedAt(context.Context, int64, time.Time) error
+	// GetDeletedAds возвращает объявления, помеченные удаленными не позже before, - те, что удалит PurgeAds
+	GetDeletedAds(context.Context, time.Time) ([]*ads.Ad, error)
+	// PurgeAds окончательно удаляет объявления, помеченные удаленными не позже before, и возвращает их количество
+	PurgeAds(context.Context, time.Time) (int, error)
+	// GetAdsByAuthor возвращает все объявления автора по возрастанию ID, в том числе помеченные удаленными.
+	// Отсутствие объявлений ошибкой не считается. GetAdsByAuthor и GetDeletedAds внутри транзакции,
+	// как и GetAdByID, блокируют найденные объявления до ее конца
+	GetAdsByAuthor(context.Context, int64) ([]*ads.Ad, error)
+	// DeleteAdsByAuthor удаляет все объявления автора, отсутствие объявлений ошибкой не считается
+	DeleteAdsByAuthor(context.Context, int64) error
+	DeleteUser(context.Context, int64) error
+	// StoreAuditEntry добавляет запись в журнал аудита под новым ID
+	StoreAuditEntry(context.Context, *audit.Entry) error
+	// AuditEntries возвращает записи журнала, подходящие под фильтр, от новых к старым
+	AuditEntries(context.Context, *audit.Filter) ([]*audit.Entry, error)
+	// StoreAttachment сохраняет описание вложения под новым ID, вложения удаляются вместе с объявлением
+	StoreAttachment(context.Context, *ads.Attachment) (int64, error)
+	// GetAttachment возвращает вложение объявления, вложение другого объявления не находится
+	GetAttachment(context.Context, int64, int64) (*ads.Attachment, error)
+	// GetAttachments возвращает вложения объявления в порядке добавления
+	GetAttachments(context.Context, int64) ([]*ads.Attachment, error)
+	DeleteAttachment(context.Context, int64, int64) error
+	// WithinTransaction выполняет fn как единицу работы: вызовы репозитория с контекстом,
+	// переданным в fn, применяются атомарно и откатываются, если fn вернула ошибку
+	WithinTransaction(context.Context, func(context.Context) error) error
+}
+
+type AdApp struct {
+	repository    Repository
+	restoreWindow time.Duration
+	retention     time.Duration
+	now           func() time.Time
+	events        *events.Bus
+	tokens        *auth.Tokens
+	passwordCost  int
+	blobs         BlobStore
+}
+
+type Option func(*AdApp)
+
+// WithRestoreWindow задает, сколько времени после удаления автор может восстановить объявление
+func WithRestoreWindow(d time.Duration) Option {
+	return func(a *AdApp) {
+		a.restoreWindow = d
+	}
+}
+
+// WithRetention задает, сколько времени удаленное объявление хранится до окончательного удаления
+func WithRetention(d time.Duration) Option {
+	return func(a *AdApp) {
+		a.retention = d
+	}
+}
+
+// WithEventBus задает шину, в которую публикуются события после каждого успешного изменения объявлений
+func WithEventBus(bus *events.Bus) Option {
+	return func(a *AdApp) {
+		a.events = bus
+	}
+}
+
+// WithTokens задает выпуск токенов доступа, по умолчанию токены подписываются случайным ключом
+func WithTokens(tokens *auth.Tokens) Option {
+	return func(a *AdApp) {
+		a.tokens = tokens
+	}
+}
+
+// WithPasswordCost задает стоимость bcrypt для хешей паролей
+func WithPasswordCost(cost int) Option {
+	return func(a *AdApp) {
+		a.passwordCost = cost
+	}
+}
+
+// WithClock подменяет источник текущего времени, используется в тестах
+func WithClock(now func() time.Time) Option {
+	return func(a *AdApp) {
+		a.now = now
+	}
+}
+
+func NewApp(repo Repository, options ...Option) App {
+	a := &AdApp{
+		repository:    repo,
+		restoreWindow: DefaultRestoreWindow,
+		retention:     DefaultRetention,
+		now:           time.Now,
+		events:        events.NewBus(),
+		passwordCost:  auth.DefaultPasswordCost,
+	}
+	for _, option := range options {
+		option(a)
+	}
+	if a.tokens == nil {
+		a.tokens = auth.NewTokens(auth.RandomSecret())
+	}
+	return a
+}
+
+// caller возвращает ID аутентифицированного пользователя, от имени которого выполняется запрос
+func caller(ctx context.Context) (int64, error) {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+func (a *AdApp) Login(ctx context.Context, userID int64, password string) (auth.Token, error) {
+
+	user, err := a.repository.GetUserByID(ctx, userID)
+
+	return a.issueToken(user, err, password)
+
+}
+
+func (a *AdApp) LoginByEmail(ctx context.Context, email string, password string) (auth.Token, error) {
+
+	user, err := a.repository.GetUserByEmail(ctx, email)
+
+	return a.issueToken(user, err, password)
+
+}
+
+// issueToken выдает токен найденному пользователю, если пароль верен.
+// Несуществующий пользователь и неверный пароль неотличимы для клиента
+func (a *AdApp) issueToken(user *users.User, err error, password string) (auth.Token, error) {
+
+	if err != nil || !auth.CheckPassword(user.PasswordHash, password) {
+		return auth.Token{}, ErrUnauthenticated
+	}
+
+	return a.tokens.Issue(user.ID)
+
+}
+
+func (a *AdApp) Authenticate(ctx context.Context, token string) (*users.User, error) {
+
+	userID, err := a.tokens.Parse(token)
+
+	if err != nil {
+		return &users.User{}, ErrUnauthenticated
+	}
+
+	// токен удаленного пользователя больше не действует
+	user, err := a.repository.GetUserByID(ctx, userID)
+
+	if err != nil {
+		return &users.User{}, ErrUnauthenticated
+	}
+
+	return user, nil
+
+}
+
+func (a *AdApp) CreateAd(ctx context.Context, title string, text string, details ads.Details) (*ads.Ad, error) {
+
+	authorId, err := caller(ctx)
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	var ad *ads.Ad
+
+	err = a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if !a.CheckUserExists(ctx, authorId) {
+			return ErrNotFound
+		}
+
+		if details.Currency == "" {
+			details.Currency = ads.DefaultCurrency
+		}
+
+		ad = &ads.Ad{Title: title, Text: text, Details: details, AuthorID: authorId, State: ads.StateDraft, CreationDate: a.now().UTC(), UpdateDate: time.Time{}, Version: 1}
+
+		if err := validateAd(ad); err != nil {
+			return err
+		}
+
+		adID, err := a.repository.StoreAd(ctx, ad)
+
+		if err != nil {
+			return err
+		}
+
+		ad.ID = adID
+
+		return a.record(ctx, authorId, ActionCreateAd, audit.ResourceAd, adID, audit.DiffAds(nil, ad))
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	a.publish(events.AdCreated, ad, nil)
+
+	return ad, nil
+
+}
+
+// actor возвращает пользователя, от имени которого выполняется запрос
+func (a *AdApp) actor(ctx context.Context) (*users.User, error) {
+
+	userID, err := caller(ctx)
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	user, err := a.repository.GetUserByID(ctx, userID)
+
+	if err != nil {
+		return &users.User{}, ErrNotFound
+	}
+
+	return user, nil
+
+}
+
+// authorizeAd возвращает объявление, если политика разрешает пользователю запроса действие над ним
+func (a *AdApp) authorizeAd(ctx context.Context, adID int64, action Action) (*ads.Ad, error) {
+
+	actor, err := a.actor(ctx)
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	ad, err := a.getAd(ctx, adID)
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	if !Can(actor, action, ad.AuthorID) {
+		return &ads.Ad{}, ErrStatusForbidden
+	}
+
+	return ad, nil
+
+}
+
+// authorizeUser проверяет, что политика разрешает пользователю запроса действие над учетной записью userID
+func (a *AdApp) authorizeUser(ctx context.Context, userID int64, action Action) error {
+
+	actor, err := a.actor(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !Can(actor, action, userID) {
+		return ErrStatusForbidden
+	}
+
+	return nil
+
+}
+
+// authorize проверяет, что политика разрешает пользователю запроса действие, у которого нет владельца
+func (a *AdApp) authorize(ctx context.Context, action Action) error {
+
+	actor, err := a.actor(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !Allowed(actor.Role, action, false) {
+		return ErrStatusForbidden
+	}
+
+	return nil
+
+}
+
+func (a *AdApp) TransitionAd(ctx context.Context, adID int64, state ads.State, reason string) (*ads.Ad, error) {
+
+	if !state.Valid() || len(reason) > MaxReasonLength {
+		return &ads.Ad{}, ErrNotValid
+	}
+
+	if state == ads.StateRejected && reason == "" {
+		return &ads.Ad{}, ErrNotValid
+	}
+
+	if state != ads.StateRejected {
+		reason = ""
+	}
+
+	var ad, prev *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		actor, err := a.actor(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		prev, err = a.getAd(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		action, ok := transitionAction(prev.State, state)
+
+		if !ok {
+			return ErrInvalidTransition
+		}
+
+		if !Can(actor, action, prev.AuthorID) {
+			return ErrStatusForbidden
+		}
+
+		if err := a.repository.UpdateAdState(ctx, adID, state, reason); err != nil {
+			return err
+		}
+
+		transition := &ads.Transition{AdID: adID, From: prev.State, To: state, ActorID: actor.ID, Reason: reason, Date: a.now().UTC()}
+
+		if err := a.repository.StoreTransition(ctx, transition); err != nil {
+			return err
+		}
+
+		ad, err = a.getAd(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		return a.record(ctx, actor.ID, action, audit.ResourceAd, adID, audit.DiffAds(prev, ad))
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	kind := events.AdStateChanged
+	switch {
+	case ad.Published():
+		kind = events.AdPublished
+	case prev.Published():
+		kind = events.AdUnpublished
+	}
+	a.publish(kind, ad, prev)
+
+	return ad, nil
+
+}
+
+func (a *AdApp) AdTransitions(ctx context.Context, adID int64) ([]*ads.Transition, error) {
+
+	if _, err := a.authorizeAd(ctx, adID, ActionViewAdTransitions); err != nil {
+		return []*ads.Transition{}, err
+	}
+
+	return a.repository.GetTransitions(ctx, adID)
+
+}
+
+func (a *AdApp) UpdateAd(ctx context.Context, adID int64, title string, text string, version int64) (*ads.Ad, error) {
+
+	return a.PatchAd(ctx, adID, AdPatch{Title: Value(title), Text: Value(text)}, version)
+
+}
+
+func (a *AdApp) PatchAd(ctx context.Context, adID int64, patch AdPatch, version int64) (*ads.Ad, error) {
+
+	var ad, prev *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		current, err := a.authorizeAd(ctx, adID, ActionUpdateAd)
+
+		if err != nil {
+			return err
+		}
+
+		before := *current
+		prev = &before
+
+		current.Title = patch.Title.Apply(current.Title)
+		current.Text = patch.Text.Apply(current.Text)
+		current.Price = patch.Price.Apply(current.Price)
+		current.Currency = patch.Currency.Apply(current.Currency)
+		current.Category = patch.Category.Apply(current.Category)
+		current.Location.Region = patch.Region.Apply(current.Location.Region)
+		current.Location.City = patch.City.Apply(current.Location.City)
+
+		if err := validateAd(current); err != nil {
+			return err
+		}
+
+		if err := a.repository.UpdateADByID(ctx, adID, current.Title, current.Text, current.Details, version); err != nil {
+			return err
+		}
+
+		ad, err = a.getAd(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		return a.recordCaller(ctx, ActionUpdateAd, audit.ResourceAd, adID, audit.DiffAds(prev, ad))
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	a.publish(events.AdUpdated, ad, prev)
+
+	return ad, nil
+
+}
+
+func (a *AdApp) CheckUserExists(ctx context.Context, userID int64) bool {
+
+	_, err := a.repository.GetUserByID(ctx, userID)
+
+	return err == nil
+
+}
+
+// validateAd проверяет поля объявления по тегам validator, а категорию - по дереву ads.Categories
+func validateAd(ad *ads.Ad) error {
+
+	if err := validator.Validate(ad); err != nil {
+		return ErrNotValid
+	}
+
+	if err := validator.Validate(ad.Details); err != nil {
+		return ErrNotValid
+	}
+
+	if !ad.Location.IsZero() {
+		if err := validator.Validate(ad.Location); err != nil {
+			return ErrNotValid
+		}
+	}
+
+	if ad.Category != "" && !ads.ValidCategory(ad.Category) {
+		return ErrNotValid
+	}
+
+	return nil
+
+}
+
+// validateUser проверяет длину никнейма по тегам validator и синтаксис email
+func validateUser(user *users.User) error {
+
+	if err := validator.Validate(user); err != nil {
+		return ErrNotValid
+	}
+
+	// адрес с отображаемым именем вроде "Bob <bob@box.com>" не принимается
+	addr, err := mail.ParseAddress(user.Email)
+
+	if err != nil || addr.Address != user.Email {
+		return ErrNotValid
+	}
+
+	return nil
+
+}
+
+func (a *AdApp) CreateUser(ctx context.Context, nickname string, email string, password string) (*users.User, error) {
+
+	if password == "" {
+		return &users.User{}, ErrNotValid
+	}
+
+	if err := validateUser(&users.User{Nickname: nickname, Email: email}); err != nil {
+		return &users.User{}, err
+	}
+
+	passwordHash, err := auth.HashPassword(password, a.passwordCost)
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	var user *users.User
+
+	err = a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		user = &users.User{Nickname: nickname, Email: email, PasswordHash: passwordHash, Role: users.RoleUser}
+
+		userID, err := a.repository.StoreUser(ctx, user)
+
+		if err != nil {
+			return err
+		}
+
+		user.ID = userID
+
+		// пользователь регистрируется сам, поэтому он же исполнитель
+		return a.record(ctx, userID, ActionCreateUser, audit.ResourceUser, userID, audit.DiffUsers(nil, user))
+
+	})
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	return user, nil
+
+}
+
+func (a *AdApp) UpdateUser(ctx context.Context, userID int64, nickname string, email string) (*users.User, error) {
+
+	return a.PatchUser(ctx, userID, UserPatch{Nickname: Value(nickname), Email: Value(email)})
+
+}
+
+func (a *AdApp) PatchUser(ctx context.Context, userID int64, patch UserPatch) (*users.User, error) {
+
+	var user *users.User
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if err := a.authorizeUser(ctx, userID, ActionUpdateUser); err != nil {
+			return err
+		}
+
+		prev, err := a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		nickname := patch.Nickname.Apply(prev.Nickname)
+		email := patch.Email.Apply(prev.Email)
+
+		if err := validateUser(&users.User{Nickname: nickname, Email: email}); err != nil {
+			return err
+		}
+
+		if err := a.repository.UpdateUserByID(ctx, userID, nickname, email); err != nil {
+			return err
+		}
+
+		user, err = a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		return a.recordCaller(ctx, ActionUpdateUser, audit.ResourceUser, userID, audit.DiffUsers(prev, user))
+
+	})
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	return user, nil
+
+}
+
+func (a *AdApp) GetUserByID(ctx context.Context, userID int64) (*users.User, error) {
+
+	user, err := a.repository.GetUserByID(ctx, userID)
+
+	if err != nil {
+		return &users.User{}, ErrNotFound
+	}
+
+	return user, nil
+
+}
+
+// GetAdByID возвращает объявление, если пользователь запроса может его видеть. Неопубликованное
+// объявление видят только автор и модераторы, для остальных его как будто нет
+func (a *AdApp) GetAdByID(ctx context.Context, adID int64) (*ads.Ad, error) {
+
+	ad, err := a.getAd(ctx, adID)
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	if ad.Published() {
+		return ad, nil
+	}
+
+	actor, err := a.actor(ctx)
+
+	if err != nil || !Can(actor, ActionViewUnpublishedAd, ad.AuthorID) {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	return ad, nil
+
+}
+
+// getAd возвращает неудаленное объявление без проверки доступа
+func (a *AdApp) getAd(ctx context.Context, adID int64) (*ads.Ad, error) {
+
+	ad, err := a.repository.GetAdByID(ctx, adID)
+
+	if err != nil || !ad.DeletedAt.IsZero() {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	return ad, nil
+
+}
+
+// authorizeFilter проверяет, что пользователь запроса может видеть объявления, которые отбирает фильтр.
+// Опубликованные видны всем, остальные - автору из filter.AuthorID и модераторам
+func (a *AdApp) authorizeFilter(ctx context.Context, filter *Filter) error {
+
+	if filter.Status == StatusPublished {
+		return nil
+	}
+
+	actor, err := a.actor(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !Can(actor, ActionViewUnpublishedAd, filter.AuthorID) {
+		return ErrStatusForbidden
+	}
+
+	return nil
+
+}
+
+func (a *AdApp) SearchAdByName(ctx context.Context, adName string) (*ads.Ad, error) {
+
+	ad, err := a.repository.SearchAdByName(ctx, adName)
+
+	if err != nil {
+		return &ads.Ad{}, ErrNotFound
+	}
+
+	return ad, nil
+
+}
+
+func (a *AdApp) SearchAds(ctx context.Context, query string, limit int) ([]*ads.Ad, error) {
+
+	if len(search.Tokenize(query)) == 0 {
+		return []*ads.Ad{}, ErrNotValid
+	}
+
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+
+	if limit < 0 || limit > MaxLimit {
+		return []*ads.Ad{}, ErrNotValid
+	}
+
+	foundAds, err := a.repository.SearchAds(ctx, query, limit)
+
+	if err != nil {
+		return []*ads.Ad{}, ErrNotFound
+	}
+
+	return foundAds, nil
+
+}
+
+func (a *AdApp) FilterAds(ctx context.Context, options ...FilterOption) ([]*ads.Ad, string, error) {
+
+	filter := NewFilter(options...)
+
+	if err := filter.prepare(); err != nil {
+		return []*ads.Ad{}, "", err
+	}
+
+	if err := a.authorizeFilter(ctx, filter); err != nil {
+		return []*ads.Ad{}, "", err
+	}
+
+	// запрашиваем на одно объявление больше, чтобы понять, есть ли следующая страница
+	query := *filter
+	if filter.Limit > 0 {
+		query.Limit = filter.Limit + 1
+	}
+
+	filteredAds, err := a.repository.FilterAds(ctx, &query)
+
+	if err != nil {
+		return []*ads.Ad{}, "", ErrNotFound
+	}
+
+	nextCursor := ""
+
+	if filter.Limit > 0 && len(filteredAds) > filter.Limit {
+		filteredAds = filteredAds[:filter.Limit]
+		nextCursor = newCursor(filter, filteredAds[len(filteredAds)-1]).Encode()
+	}
+
+	return filteredAds, nextCursor, nil
+
+}
+
+func (a *AdApp) DeleteAd(ctx context.Context, adID int64) (*ads.Ad, error) {
+
+	var ad, prev *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		var err error
+		prev, err = a.authorizeAd(ctx, adID, ActionDeleteAd)
+
+		if err != nil {
+			return err
+		}
+
+		if err := a.repository.SetAdDeletedAt(ctx, adID, a.now().UTC()); err != nil {
+			return err
+		}
+
+		ad, err = a.repository.GetAdByID(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		return a.recordCaller(ctx, ActionDeleteAd, audit.ResourceAd, adID, audit.DiffAds(prev, ad))
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	a.publish(events.AdDeleted, ad, prev)
+
+	return ad, nil
+
+}
+
+// RestoreAd снимает пометку об удалении, если с момента удаления прошло не больше окна восстановления
+func (a *AdApp) RestoreAd(ctx context.Context, adID int64) (*ads.Ad, error) {
+
+	var ad, deleted *ads.Ad
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		actor, err := a.actor(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		deleted, err = a.repository.GetAdByID(ctx, adID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if !Can(actor, ActionRestoreAd, deleted.AuthorID) {
+			return ErrStatusForbidden
+		}
+
+		if deleted.DeletedAt.IsZero() {
+			return ErrNotValid
+		}
+
+		if a.now().Sub(deleted.DeletedAt) > a.restoreWindow {
+			return ErrNotFound
+		}
+
+		if err := a.repository.SetAdDeletedAt(ctx, adID, time.Time{}); err != nil {
+			return err
+		}
+
+		ad, err = a.getAd(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		return a.record(ctx, actor.ID, ActionRestoreAd, audit.ResourceAd, adID, audit.DiffAds(deleted, ad))
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	a.publish(events.AdRestored, ad, deleted)
+
+	return ad, nil
+
+}
+
+func (a *AdApp) PurgeDeletedAds(ctx context.Context) (int, error) {
+
+	purged := 0
+	var keys []string
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		before := a.now().Add(-a.retention).UTC()
+
+		expired, err := a.repository.GetDeletedAds(ctx, before)
+
+		if err != nil {
+			return err
+		}
+
+		keys, err = a.attachmentKeys(ctx, expired)
+
+		if err != nil {
+			return err
+		}
+
+		purged, err = a.repository.PurgeAds(ctx, before)
+
+		if err != nil || purged == 0 {
+			return err
+		}
+
+		// журнал хранит одну запись на запуск очистки с количеством удаленных объявлений
+		changes := []audit.Change{{Field: "purged", After: strconv.Itoa(purged)}}
+
+		return a.record(ctx, audit.SystemActor, ActionPurgeAds, audit.ResourceAd, -1, changes)
+
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	a.deleteBlobs(keys)
+
+	return purged, nil
+
+}
+
+func (a *AdApp) DeleteUser(ctx context.Context, userID int64) (*users.User, error) {
+
+	var user *users.User
+	var userAds []*ads.Ad
+	var keys []string
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if err := a.authorizeUser(ctx, userID, ActionDeleteUser); err != nil {
+			return err
+		}
+
+		var err error
+		user, err = a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		// запоминаем удаляемые объявления, в том числе помеченные удаленными, чтобы записать их в журнал
+		// и оповестить подписчиков
+		userAds, err = a.repository.GetAdsByAuthor(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		keys, err = a.attachmentKeys(ctx, userAds)
+
+		if err != nil {
+			return err
+		}
+
+		if err := a.repository.DeleteAdsByAuthor(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := a.repository.DeleteUser(ctx, userID); err != nil {
+			return err
+		}
+
+		for _, ad := range userAds {
+			deleted := removedAd(ad, a.now())
+
+			if err := a.recordCaller(ctx, ActionDeleteUser, audit.ResourceAd, ad.ID, audit.DiffAds(ad, deleted)); err != nil {
+				return err
+			}
+		}
+
+		return a.recordCaller(ctx, ActionDeleteUser, audit.ResourceUser, userID, audit.DiffUsers(user, nil))
+
+	})
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	a.deleteBlobs(keys)
+
+	for _, ad := range userAds {
+		a.publish(events.AdDeleted, removedAd(ad, a.now()), ad)
+	}
+
+	return user, nil
+
+}
+
+// removedAd возвращает копию окончательно удаляемого объявления. Время удаления уже
+// помеченного объявления не меняется
+func removedAd(ad *ads.Ad, now time.Time) *ads.Ad {
+	deleted := *ad
+	if deleted.DeletedAt.IsZero() {
+		deleted.DeletedAt = now.UTC()
+	}
+	return &deleted
+}
+
+func (a *AdApp) SetUserRole(ctx context.Context, userID int64, role users.Role) (*users.User, error) {
+
+	if !role.Valid() {
+		return &users.User{}, ErrNotValid
+	}
+
+	var user *users.User
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if err := a.authorizeUser(ctx, userID, ActionSetUserRole); err != nil {
+			return err
+		}
+
+		prev, err := a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if err := a.repository.UpdateUserRole(ctx, userID, role); err != nil {
+			return ErrNotFound
+		}
+
+		user, err = a.repository.GetUserByID(ctx, userID)
+
+		if err != nil {
+			return err
+		}
+
+		return a.recordCaller(ctx, ActionSetUserRole, audit.ResourceUser, userID, audit.DiffUsers(prev, user))
+
+	})
+
+	if err != nil {
+		return &users.User{}, err
+	}
+
+	return user, nil
+
+}
+
+func (a *AdApp) WatchAds(ctx context.Context, options ...FilterOption) (*events.Subscription, error) {
+
+	filter := NewFilter(options...)
+
+	if !filter.Status.Valid() {
+		return nil, ErrNotValid
+	}
+
+	if err := a.authorizeFilter(ctx, filter); err != nil {
+		return nil, err
+	}
+
+	sub := a.events.Subscribe(func(e events.Event) (events.Event, bool) {
+		if filter.Match(&e.Ad) {
+			return e, true
+		}
+		if e.Prev == nil || !filter.Match(e.Prev) {
+			return e, false
+		}
+		// объявление перестало подходить под фильтр, и новое содержимое подписчику может быть недоступно,
+		// например причина отклонения: он узнает только ID объявления и что с ним произошло
+		return events.Event{Kind: e.Kind, Ad: ads.Ad{ID: e.Ad.ID, DeletedAt: e.Ad.DeletedAt}}, true
+	})
+
+	go func() {
+		<-ctx.Done()
+		sub.Close()
+	}()
+
+	return sub, nil
+
+}
+
+func (a *AdApp) AdHistory(ctx context.Context, adID int64) ([]*audit.Entry, error) {
+
+	actor, err := a.actor(ctx)
+
+	if err != nil {
+		return []*audit.Entry{}, err
+	}
+
+	// история удаленного объявления нужна для разбора споров, поэтому пометка об удалении не учитывается
+	ad, err := a.repository.GetAdByID(ctx, adID)
+
+	if err != nil {
+		return []*audit.Entry{}, ErrNotFound
+	}
+
+	if !Can(actor, ActionViewAdHistory, ad.AuthorID) {
+		return []*audit.Entry{}, ErrStatusForbidden
+	}
+
+	filter := audit.NewFilter()
+	filter.Resource = audit.ResourceAd
+	filter.ResourceID = adID
+
+	entries, err := a.repository.AuditEntries(ctx, filter)
+
+	if err != nil {
+		return []*audit.Entry{}, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+
+}
+
+func (a *AdApp) AuditLog(ctx context.Context, limit int, cursor string) ([]*audit.Entry, string, error) {
+
+	if err := a.authorize(ctx, ActionViewAuditLog); err != nil {
+		return []*audit.Entry{}, "", err
+	}
+
+	if limit == 0 {
+		limit = DefaultAuditLimit
+	}
+
+	if limit < 0 || limit > MaxLimit {
+		return []*audit.Entry{}, "", ErrNotValid
+	}
+
+	filter := audit.NewFilter()
+	// запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	filter.Limit = limit + 1
+
+	if cursor != "" {
+		beforeID, err := audit.DecodeCursor(cursor)
+
+		if err != nil {
+			return []*audit.Entry{}, "", ErrNotValid
+		}
+
+		filter.BeforeID = beforeID
+	}
+
+	entries, err := a.repository.AuditEntries(ctx, filter)
+
+	if err != nil {
+		return []*audit.Entry{}, "", err
+	}
+
+	nextCursor := ""
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+		nextCursor = audit.EncodeCursor(entries[len(entries)-1].ID)
+	}
+
+	return entries, nextCursor, nil
+
+}
+
+// record добавляет запись в журнал аудита, вызывается в транзакции изменения
+func (a *AdApp) record(ctx context.Context, actorID int64, action Action, resource audit.Resource, resourceID int64, changes []audit.Change) error {
+	return a.repository.StoreAuditEntry(ctx, &audit.Entry{
+		ActorID:    actorID,
+		Action:     string(action),
+		Resource:   resource,
+		ResourceID: resourceID,
+		Changes:    changes,
+		Date:       a.now().UTC(),
+	})
+}
+
+// recordCaller добавляет запись в журнал аудита от имени пользователя запроса
+func (a *AdApp) recordCaller(ctx context.Context, action Action, resource audit.Resource, resourceID int64, changes []audit.Change) error {
+	actorID, err := caller(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return a.record(ctx, actorID, action, resource, resourceID, changes)
+}
+
+// publish оповещает подписчиков об успешном изменении объявления
+func (a *AdApp) publish(kind events.Kind, ad *ads.Ad, prev *ads.Ad) {
+	a.events.Publish(events.Event{Kind: kind, Ad: *ad, Prev: prev})
+}
@@ -0,0 +1,321 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"homework9/internal/ads"
+	"homework9/internal/audit"
+)
+
+var (
+	// ErrTooLarge - вложение больше MaxAttachmentSize
+	ErrTooLarge = errors.New("too large")
+	// ErrUnsupportedMediaType - тип содержимого вложения не входит в AttachmentTypes
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrNoBlobStore - приложение создано без WithBlobStore, вложения недоступны
+	ErrNoBlobStore = errors.New("attachments are not configured")
+)
+
+const (
+	MaxAttachmentSize       = 5 << 20
+	MaxAttachments          = 10
+	MaxAttachmentNameLength = 255
+)
+
+// AttachmentTypes - допустимые типы содержимого вложений
+var AttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// BlobStore хранит содержимое вложений по ключу.
+// Put сохраняет содержимое целиком или не сохраняет ничего, если чтение r завершилось ошибкой.
+// Get и Delete возвращают ErrNotFound реализации, если ключа нет
+type BlobStore interface {
+	Put(context.Context, string, io.Reader) error
+	Get(context.Context, string) (io.ReadCloser, error)
+	Delete(context.Context, string) error
+}
+
+// WithBlobStore задает хранилище содержимого вложений, без него вложения недоступны
+func WithBlobStore(store BlobStore) Option {
+	return func(a *AdApp) {
+		a.blobs = store
+	}
+}
+
+// sizeLimiter считает прочитанные байты и возвращает ErrTooLarge, как только их больше limit
+type sizeLimiter struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (l *sizeLimiter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// newBlobKey возвращает случайный ключ содержимого, по которому нельзя угадать ключи других вложений
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// attachmentName оставляет от имени файла клиента только последний элемент пути
+func attachmentName(name string) (string, error) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	if name == "." || name == "/" {
+		name = "attachment"
+	}
+
+	if len(name) > MaxAttachmentNameLength {
+		return "", ErrNotValid
+	}
+
+	return name, nil
+}
+
+// sniff определяет тип содержимого по первым байтам, как браузер, и проверяет, что он допустим
+func sniff(r *bufio.Reader) (string, error) {
+	head, err := r.Peek(512)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if len(head) == 0 {
+		return "", ErrNotValid
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+
+	if err != nil || !AttachmentTypes[contentType] {
+		return "", ErrUnsupportedMediaType
+	}
+
+	return contentType, nil
+}
+
+func (a *AdApp) AddAttachment(ctx context.Context, adID int64, name string, r io.Reader) (*ads.Attachment, error) {
+
+	if a.blobs == nil {
+		return &ads.Attachment{}, ErrNoBlobStore
+	}
+
+	name, err := attachmentName(name)
+
+	if err != nil {
+		return &ads.Attachment{}, err
+	}
+
+	// чужое содержимое не загружаем вовсе, права проверяются еще раз при сохранении
+	if _, err := a.authorizeAd(ctx, adID, ActionUpdateAd); err != nil {
+		return &ads.Attachment{}, err
+	}
+
+	br := bufio.NewReaderSize(r, 512)
+
+	contentType, err := sniff(br)
+
+	if err != nil {
+		return &ads.Attachment{}, err
+	}
+
+	key, err := newBlobKey()
+
+	if err != nil {
+		return &ads.Attachment{}, err
+	}
+
+	content := &sizeLimiter{r: br, limit: MaxAttachmentSize}
+
+	// содержимое загружается вне транзакции, чтобы медленный клиент не держал блокировки хранилища
+	if err := a.blobs.Put(ctx, key, content); err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return &ads.Attachment{}, ErrTooLarge
+		}
+		return &ads.Attachment{}, err
+	}
+
+	attachment := &ads.Attachment{AdID: adID, Name: name, ContentType: contentType, Size: content.n, Key: key, CreationDate: a.now().UTC()}
+
+	err = a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := a.authorizeAd(ctx, adID, ActionUpdateAd); err != nil {
+			return err
+		}
+
+		attachments, err := a.repository.GetAttachments(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		if len(attachments) >= MaxAttachments {
+			return fmt.Errorf("%w: ad already has %d attachments", ErrNotValid, MaxAttachments)
+		}
+
+		attachment.ID, err = a.repository.StoreAttachment(ctx, attachment)
+
+		if err != nil {
+			return err
+		}
+
+		// вложения входят в представление объявления, поэтому меняют его версию и ETag
+		if err := a.repository.BumpAdVersion(ctx, adID); err != nil {
+			return err
+		}
+
+		changes := []audit.Change{{Field: "attachments", After: attachment.Name}}
+
+		return a.recordCaller(ctx, ActionAddAttachment, audit.ResourceAd, adID, changes)
+
+	})
+
+	if err != nil {
+		// без описания содержимое недостижимо, поэтому удаляется сразу
+		_ = a.blobs.Delete(context.Background(), key)
+		return &ads.Attachment{}, err
+	}
+
+	return attachment, nil
+
+}
+
+func (a *AdApp) OpenAttachment(ctx context.Context, adID int64, attachmentID int64) (*ads.Attachment, io.ReadCloser, error) {
+
+	if a.blobs == nil {
+		return &ads.Attachment{}, nil, ErrNoBlobStore
+	}
+
+	// вложения видны тем же, кому видно объявление
+	if _, err := a.GetAdByID(ctx, adID); err != nil {
+		return &ads.Attachment{}, nil, err
+	}
+
+	attachment, err := a.repository.GetAttachment(ctx, adID, attachmentID)
+
+	if err != nil {
+		return &ads.Attachment{}, nil, ErrNotFound
+	}
+
+	content, err := a.blobs.Get(ctx, attachment.Key)
+
+	if err != nil {
+		return &ads.Attachment{}, nil, ErrNotFound
+	}
+
+	return attachment, content, nil
+
+}
+
+func (a *AdApp) DeleteAttachment(ctx context.Context, adID int64, attachmentID int64) (*ads.Ad, error) {
+
+	if a.blobs == nil {
+		return &ads.Ad{}, ErrNoBlobStore
+	}
+
+	var ad *ads.Ad
+	var attachment *ads.Attachment
+
+	err := a.repository.WithinTransaction(ctx, func(ctx context.Context) error {
+
+		if _, err := a.authorizeAd(ctx, adID, ActionUpdateAd); err != nil {
+			return err
+		}
+
+		var err error
+		attachment, err = a.repository.GetAttachment(ctx, adID, attachmentID)
+
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if err := a.repository.DeleteAttachment(ctx, adID, attachmentID); err != nil {
+			return err
+		}
+
+		if err := a.repository.BumpAdVersion(ctx, adID); err != nil {
+			return err
+		}
+
+		ad, err = a.getAd(ctx, adID)
+
+		if err != nil {
+			return err
+		}
+
+		changes := []audit.Change{{Field: "attachments", Before: attachment.Name}}
+
+		return a.recordCaller(ctx, ActionDeleteAttachment, audit.ResourceAd, adID, changes)
+
+	})
+
+	if err != nil {
+		return &ads.Ad{}, err
+	}
+
+	// описание уже удалено, так что оставшееся из-за ошибки содержимое никому не видно
+	_ = a.blobs.Delete(ctx, attachment.Key)
+
+	return ad, nil
+
+}
+
+// attachmentKeys возвращает ключи содержимого вложений объявлений, которые удаляются в текущей транзакции.
+// Содержимое удаляется после ее фиксации, иначе откат оставил бы вложения без содержимого
+func (a *AdApp) attachmentKeys(ctx context.Context, list []*ads.Ad) ([]string, error) {
+
+	keys := []string{}
+
+	for _, ad := range list {
+		attachments, err := a.repository.GetAttachments(ctx, ad.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, attachment := range attachments {
+			keys = append(keys, attachment.Key)
+		}
+	}
+
+	return keys, nil
+
+}
+
+// deleteBlobs удаляет содержимое вложений, описания которых уже удалены. Как и в DeleteAttachment,
+// оставшееся из-за ошибки содержимое никому не видно, поэтому ошибки не возвращаются
+func (a *AdApp) deleteBlobs(keys []string) {
+
+	if a.blobs == nil {
+		return
+	}
+
+	for _, key := range keys {
+		_ = a.blobs.Delete(context.Background(), key)
+	}
+
+}
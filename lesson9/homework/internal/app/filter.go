@@ -0,0 +1,246 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	"homework9/internal/ads"
+)
+
+// PublishStatus отбирает объявления по состоянию. Кроме перечисленных значений,
+// статусом может быть любое состояние объявления (ads.State), тогда отбираются объявления ровно в нем
+type PublishStatus string
+
+const (
+	StatusPublished   PublishStatus = "published"
+	StatusUnpublished PublishStatus = "unpublished"
+	StatusAll         PublishStatus = "all"
+)
+
+func (s PublishStatus) Valid() bool {
+	switch s {
+	case StatusPublished, StatusUnpublished, StatusAll:
+		return true
+	}
+	return ads.State(s).Valid()
+}
+
+type Filter struct {
+	AuthorID        int64
+	PublishedAfter  time.Time
+	PublishedBefore time.Time
+	Status          PublishStatus
+	// TitleContains - подстрока заголовка без учета регистра
+	TitleContains string
+	// UpdatedAfter и UpdatedBefore отбирают только объявления, которые хотя бы раз обновлялись
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	SortBy        SortField
+	Descending    bool
+	// Limit - размер страницы, 0 - без ограничения
+	Limit int
+	// Cursor - непрозрачный курсор из next_cursor предыдущей страницы
+	Cursor string
+	// After - разобранный Cursor, заполняется приложением перед обращением к репозиторию
+	After *Cursor
+	// MinPrice и MaxPrice - границы цены включительно, -1 - без границы.
+	// Цены в разных валютах не пересчитываются, поэтому границы обычно задаются вместе с Currency
+	MinPrice int64
+	MaxPrice int64
+	Currency string
+	// Category отбирает объявления из категории и всех ее подкатегорий
+	Category string
+	// Region и City сравниваются без учета регистра
+	Region string
+	City   string
+}
+
+type FilterOption func(*Filter)
+
+func NewFilter(options ...FilterOption) *Filter {
+	filter := &Filter{
+		AuthorID:        -1,
+		PublishedAfter:  time.Time{},
+		PublishedBefore: time.Time{},
+		Status:          StatusPublished,
+		TitleContains:   "",
+		UpdatedAfter:    time.Time{},
+		UpdatedBefore:   time.Time{},
+		SortBy:          SortByCreationDate,
+		Descending:      false,
+		Limit:           0,
+		Cursor:          "",
+		MinPrice:        -1,
+		MaxPrice:        -1,
+		Currency:        "",
+		Category:        "",
+		Region:          "",
+		City:            "",
+	}
+
+	for _, option := range options {
+		option(filter)
+	}
+
+	return filter
+}
+
+func WithAuthorID(author_id int64) FilterOption {
+	return func(filter *Filter) {
+		filter.AuthorID = author_id
+	}
+}
+
+func WithPublishedAfter(publishedAfter time.Time) FilterOption {
+	return func(filter *Filter) {
+		filter.PublishedAfter = publishedAfter
+	}
+}
+
+func WithPublishedBefore(publishedBefore time.Time) FilterOption {
+	return func(filter *Filter) {
+		filter.PublishedBefore = publishedBefore
+	}
+}
+
+func WithStatus(status PublishStatus) FilterOption {
+	return func(filter *Filter) {
+		filter.Status = status
+	}
+}
+
+func WithTitleContains(substr string) FilterOption {
+	return func(filter *Filter) {
+		filter.TitleContains = substr
+	}
+}
+
+func WithUpdatedAfter(updatedAfter time.Time) FilterOption {
+	return func(filter *Filter) {
+		filter.UpdatedAfter = updatedAfter
+	}
+}
+
+func WithUpdatedBefore(updatedBefore time.Time) FilterOption {
+	return func(filter *Filter) {
+		filter.UpdatedBefore = updatedBefore
+	}
+}
+
+func WithMinPrice(minPrice int64) FilterOption {
+	return func(filter *Filter) {
+		filter.MinPrice = minPrice
+	}
+}
+
+func WithMaxPrice(maxPrice int64) FilterOption {
+	return func(filter *Filter) {
+		filter.MaxPrice = maxPrice
+	}
+}
+
+func WithCurrency(currency string) FilterOption {
+	return func(filter *Filter) {
+		filter.Currency = currency
+	}
+}
+
+func WithCategory(category string) FilterOption {
+	return func(filter *Filter) {
+		filter.Category = category
+	}
+}
+
+func WithRegion(region string) FilterOption {
+	return func(filter *Filter) {
+		filter.Region = region
+	}
+}
+
+func WithCity(city string) FilterOption {
+	return func(filter *Filter) {
+		filter.City = city
+	}
+}
+
+func WithSort(sortBy SortField, descending bool) FilterOption {
+	return func(filter *Filter) {
+		filter.SortBy = sortBy
+		filter.Descending = descending
+	}
+}
+
+func WithLimit(limit int) FilterOption {
+	return func(filter *Filter) {
+		filter.Limit = limit
+	}
+}
+
+func WithCursor(cursor string) FilterOption {
+	return func(filter *Filter) {
+		filter.Cursor = cursor
+	}
+}
+
+// Match проверяет объявление на соответствие всем критериям фильтра, кроме курсора.
+// Удаленные объявления не подходят ни под один фильтр
+func (f *Filter) Match(ad *ads.Ad) bool {
+	if !ad.DeletedAt.IsZero() {
+		return false
+	}
+	switch f.Status {
+	case StatusPublished:
+		if !ad.Published() {
+			return false
+		}
+	case StatusUnpublished:
+		if ad.Published() {
+			return false
+		}
+	case StatusAll:
+	default:
+		if ad.State != ads.State(f.Status) {
+			return false
+		}
+	}
+	if f.AuthorID != -1 && ad.AuthorID != f.AuthorID {
+		return false
+	}
+	if !f.PublishedAfter.IsZero() && ad.CreationDate.Before(f.PublishedAfter) {
+		return false
+	}
+	if !f.PublishedBefore.IsZero() && ad.CreationDate.After(f.PublishedBefore) {
+		return false
+	}
+	if f.TitleContains != "" && !strings.Contains(strings.ToLower(ad.Title), strings.ToLower(f.TitleContains)) {
+		return false
+	}
+	if (!f.UpdatedAfter.IsZero() || !f.UpdatedBefore.IsZero()) && ad.UpdateDate.IsZero() {
+		return false
+	}
+	if !f.UpdatedAfter.IsZero() && ad.UpdateDate.Before(f.UpdatedAfter) {
+		return false
+	}
+	if !f.UpdatedBefore.IsZero() && ad.UpdateDate.After(f.UpdatedBefore) {
+		return false
+	}
+	if f.MinPrice != -1 && ad.Price < f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != -1 && ad.Price > f.MaxPrice {
+		return false
+	}
+	if f.Currency != "" && ad.Currency != f.Currency {
+		return false
+	}
+	if f.Category != "" && !ads.InCategory(ad.Category, f.Category) {
+		return false
+	}
+	if f.Region != "" && !strings.EqualFold(ad.Location.Region, f.Region) {
+		return false
+	}
+	if f.City != "" && !strings.EqualFold(ad.Location.City, f.City) {
+		return false
+	}
+	return true
+}
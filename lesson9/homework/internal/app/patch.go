@@ -0,0 +1,48 @@
+package app
+
+// Field - поле частичного изменения: не переданное поле не меняется, null сбрасывает его в нулевое значение,
+// а дальше значение проходит ту же валидацию, что и при полном изменении
+type Field[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+}
+
+// Value - поле, переданное со значением
+func Value[T any](v T) Field[T] {
+	return Field[T]{Value: v, Set: true}
+}
+
+// Null - поле, переданное как null
+func Null[T any]() Field[T] {
+	return Field[T]{Set: true, Null: true}
+}
+
+// Apply возвращает значение поля после изменения
+func (f Field[T]) Apply(current T) T {
+	if !f.Set {
+		return current
+	}
+	if f.Null {
+		var zero T
+		return zero
+	}
+	return f.Value
+}
+
+// AdPatch - частичное изменение объявления
+type AdPatch struct {
+	Title    Field[string]
+	Text     Field[string]
+	Price    Field[int64]
+	Currency Field[string]
+	Category Field[string]
+	Region   Field[string]
+	City     Field[string]
+}
+
+// UserPatch - частичное изменение пользователя
+type UserPatch struct {
+	Nickname Field[string]
+	Email    Field[string]
+}
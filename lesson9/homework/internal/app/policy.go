@@ -0,0 +1,97 @@
+package app
+
+import "homework9/internal/users"
+
+// Action - действие над объявлением или учетной записью, доступ к которому решает политика
+type Action string
+
+const (
+	ActionUpdateAd          Action = "update_ad"
+	ActionSubmitAd          Action = "submit_ad"
+	ActionWithdrawAd        Action = "withdraw_ad"
+	ActionApproveAd         Action = "approve_ad"
+	ActionRejectAd          Action = "reject_ad"
+	ActionArchiveAd         Action = "archive_ad"
+	ActionReopenAd          Action = "reopen_ad"
+	ActionViewAdTransitions Action = "view_ad_transitions"
+	ActionViewAdHistory     Action = "view_ad_history"
+	ActionViewAuditLog      Action = "view_audit_log"
+	ActionViewUnpublishedAd Action = "view_unpublished_ad"
+	ActionDeleteAd          Action = "delete_ad"
+	ActionRestoreAd         Action = "restore_ad"
+	ActionUpdateUser        Action = "update_user"
+	ActionDeleteUser        Action = "delete_user"
+	ActionSetUserRole       Action = "set_user_role"
+)
+
+// Действия, которые доступны всем и не проверяются политикой, но попадают в журнал аудита
+const (
+	ActionCreateAd   Action = "create_ad"
+	ActionCreateUser Action = "create_user"
+	ActionPurgeAds   Action = "purge_ads"
+	// вложения меняет тот, кому разрешено ActionUpdateAd
+	ActionAddAttachment    Action = "add_attachment"
+	ActionDeleteAttachment Action = "delete_attachment"
+)
+
+// rule описывает, кому разрешено действие
+type rule struct {
+	// owner - владельцу ресурса: автору объявления или самому пользователю
+	owner bool
+	// roles - пользователям с этими ролями над чужими ресурсами
+	roles []users.Role
+}
+
+var (
+	moderators = []users.Role{users.RoleModerator, users.RoleAdmin}
+	admins     = []users.Role{users.RoleAdmin}
+)
+
+// policy - все правила доступа приложения. Авторы отправляют объявления на проверку, модераторы
+// одобряют, отклоняют, архивируют и удаляют любые объявления, но не редактируют чужие;
+// администраторы, кроме того, управляют пользователями
+var policy = map[Action]rule{
+	ActionUpdateAd:          {owner: true},
+	ActionSubmitAd:          {owner: true},
+	ActionWithdrawAd:        {owner: true},
+	ActionApproveAd:         {roles: moderators},
+	ActionRejectAd:          {roles: moderators},
+	ActionArchiveAd:         {owner: true, roles: moderators},
+	ActionReopenAd:          {owner: true},
+	ActionViewAdTransitions: {owner: true, roles: moderators},
+	ActionViewAdHistory:     {owner: true, roles: moderators},
+	ActionViewAuditLog:      {roles: admins},
+	ActionViewUnpublishedAd: {owner: true, roles: moderators},
+	ActionDeleteAd:          {owner: true, roles: moderators},
+	ActionRestoreAd:         {owner: true, roles: moderators},
+	ActionUpdateUser:        {owner: true, roles: admins},
+	ActionDeleteUser:        {owner: true, roles: admins},
+	ActionSetUserRole:       {roles: admins},
+}
+
+// Can сообщает, может ли actor выполнить action над ресурсом, принадлежащим ownerID
+func Can(actor *users.User, action Action, ownerID int64) bool {
+	return Allowed(actor.Role, action, actor.ID == ownerID)
+}
+
+// Allowed сообщает, разрешено ли action пользователю с ролью role; owner - пользователь владеет ресурсом.
+// Для действий без владельца, например просмотра журнала аудита, owner равен false.
+// Неизвестные действия запрещены
+func Allowed(role users.Role, action Action, owner bool) bool {
+	r, ok := policy[action]
+	if !ok {
+		return false
+	}
+
+	if r.owner && owner {
+		return true
+	}
+
+	for _, allowed := range r.roles {
+		if role == allowed {
+			return true
+		}
+	}
+
+	return false
+}
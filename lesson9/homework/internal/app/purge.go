@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPurge раз в interval окончательно удаляет объявления с истекшим сроком хранения,
+// пока не будет отменен ctx
+func RunPurge(ctx context.Context, a App, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			purged, err := a.PurgeDeletedAds(ctx)
+			if err != nil {
+				log.Printf("purge deleted ads: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("purged %d deleted ads", purged)
+			}
+		}
+	}
+}
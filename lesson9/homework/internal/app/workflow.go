@@ -0,0 +1,36 @@
+package app
+
+import "homework9/internal/ads"
+
+// MaxReasonLength - наибольшая длина причины перехода в байтах
+const MaxReasonLength = 500
+
+// transitions - допустимые переходы между состояниями объявления и действия, которые их разрешают.
+// Новое объявление создается черновиком, видны всем только опубликованные объявления
+var transitions = map[ads.State]map[ads.State]Action{
+	ads.StateDraft: {
+		ads.StatePending: ActionSubmitAd,
+	},
+	ads.StatePending: {
+		ads.StatePublished: ActionApproveAd,
+		ads.StateRejected:  ActionRejectAd,
+		ads.StateDraft:     ActionWithdrawAd,
+	},
+	ads.StatePublished: {
+		ads.StateArchived: ActionArchiveAd,
+		ads.StateRejected: ActionRejectAd,
+	},
+	ads.StateRejected: {
+		ads.StatePending: ActionSubmitAd,
+		ads.StateDraft:   ActionReopenAd,
+	},
+	ads.StateArchived: {
+		ads.StateDraft: ActionReopenAd,
+	},
+}
+
+// transitionAction возвращает действие, разрешающее переход from -> to, или false, если переход недопустим
+func transitionAction(from ads.State, to ads.State) (Action, bool) {
+	action, ok := transitions[from][to]
+	return action, ok
+}
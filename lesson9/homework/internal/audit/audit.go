@@ -0,0 +1,146 @@
+// Package audit - журнал изменений объявлений и пользователей: кто, что и когда изменил
+package audit
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"homework9/internal/ads"
+	"homework9/internal/users"
+)
+
+// ErrInvalidCursor - курсор журнала поврежден или выдан не этим журналом
+var ErrInvalidCursor = errors.New("invalid audit cursor")
+
+// SystemActor - ID исполнителя изменений, сделанных самим сервисом, например очисткой удаленных объявлений
+const SystemActor int64 = -1
+
+type Resource string
+
+const (
+	ResourceAd   Resource = "ad"
+	ResourceUser Resource = "user"
+)
+
+// Change - изменение одного поля, пустая строка означает отсутствие значения
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type Entry struct {
+	ID         int64
+	ActorID    int64
+	Action     string
+	Resource   Resource
+	ResourceID int64
+	Changes    []Change
+	Date       time.Time
+}
+
+// Filter отбирает записи журнала, записи возвращаются от новых к старым
+type Filter struct {
+	// Resource - пустая строка не ограничивает выборку
+	Resource Resource
+	// ResourceID - -1 не ограничивает выборку
+	ResourceID int64
+	// BeforeID - только записи с меньшим ID, -1 не ограничивает выборку
+	BeforeID int64
+	// Limit - 0 без ограничения
+	Limit int
+}
+
+func NewFilter() *Filter {
+	return &Filter{Resource: "", ResourceID: -1, BeforeID: -1, Limit: 0}
+}
+
+// Match проверяет запись на соответствие фильтру, кроме Limit
+func (f *Filter) Match(e *Entry) bool {
+	if f.Resource != "" && e.Resource != f.Resource {
+		return false
+	}
+	if f.ResourceID != -1 && e.ResourceID != f.ResourceID {
+		return false
+	}
+	if f.BeforeID != -1 && e.ID >= f.BeforeID {
+		return false
+	}
+	return true
+}
+
+// EncodeCursor возвращает курсор страницы, следующей за записью с ID id
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor возвращает ID последней записи предыдущей страницы
+func DecodeCursor(s string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// diff добавляет изменение поля, если значения различаются
+func diff(changes []Change, field string, before string, after string) []Change {
+	if before == after {
+		return changes
+	}
+	return append(changes, Change{Field: field, Before: before, After: after})
+}
+
+// DiffAds возвращает изменившиеся поля объявления, before = nil - объявление создано
+func DiffAds(before *ads.Ad, after *ads.Ad) []Change {
+	if before == nil {
+		before = &ads.Ad{}
+	}
+
+	changes := []Change{}
+	changes = diff(changes, "title", before.Title, after.Title)
+	changes = diff(changes, "text", before.Text, after.Text)
+	changes = diff(changes, "state", string(before.State), string(after.State))
+	changes = diff(changes, "rejection_reason", before.RejectionReason, after.RejectionReason)
+	changes = diff(changes, "price", strconv.FormatInt(before.Price, 10), strconv.FormatInt(after.Price, 10))
+	changes = diff(changes, "currency", before.Currency, after.Currency)
+	changes = diff(changes, "category", before.Category, after.Category)
+	changes = diff(changes, "region", before.Location.Region, after.Location.Region)
+	changes = diff(changes, "city", before.Location.City, after.Location.City)
+	changes = diff(changes, "deleted_at", formatTime(before.DeletedAt), formatTime(after.DeletedAt))
+
+	return changes
+}
+
+// DiffUsers возвращает изменившиеся поля пользователя, before = nil - пользователь создан,
+// after = nil - удален. Хеш пароля в журнал не попадает
+func DiffUsers(before *users.User, after *users.User) []Change {
+	if before == nil {
+		before = &users.User{}
+	}
+	if after == nil {
+		after = &users.User{}
+	}
+
+	changes := []Change{}
+	changes = diff(changes, "nickname", before.Nickname, after.Nickname)
+	changes = diff(changes, "email", before.Email, after.Email)
+	changes = diff(changes, "role", string(before.Role), string(after.Role))
+
+	return changes
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type userIDKey struct{}
+
+// WithUserID сохраняет в контексте ID аутентифицированного пользователя
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext возвращает ID аутентифицированного пользователя, ok = false для анонимного запроса
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int64)
+	return userID, ok
+}
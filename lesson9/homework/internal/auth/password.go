@@ -0,0 +1,24 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// Стоимость bcrypt: чем она выше, тем дольше подбор пароля по утекшему хешу и тем дольше вход.
+// MinPasswordCost годится только для тестов
+const (
+	DefaultPasswordCost = bcrypt.DefaultCost
+	MinPasswordCost     = bcrypt.MinCost
+)
+
+// HashPassword возвращает bcrypt-хеш пароля для хранения в репозитории
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сообщает, соответствует ли пароль хешу
+func CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
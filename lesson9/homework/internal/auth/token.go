@@ -0,0 +1,132 @@
+// Package auth выдает и проверяет токены доступа и хранит аутентифицированного пользователя в контексте
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+const DefaultTokenTTL = 24 * time.Hour
+
+// Token - выданный токен доступа
+type Token struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// tokenHeader - заголовок JWT, подписанного HMAC-SHA256
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Tokens выдает JWT (HS256) с ID пользователя в sub и проверяет их подпись и срок действия
+type Tokens struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+type TokensOption func(*Tokens)
+
+// WithTokenTTL задает срок действия выдаваемых токенов
+func WithTokenTTL(ttl time.Duration) TokensOption {
+	return func(t *Tokens) {
+		t.ttl = ttl
+	}
+}
+
+// WithTokenClock подменяет источник текущего времени, используется в тестах
+func WithTokenClock(now func() time.Time) TokensOption {
+	return func(t *Tokens) {
+		t.now = now
+	}
+}
+
+func NewTokens(secret []byte, options ...TokensOption) *Tokens {
+	t := &Tokens{secret: secret, ttl: DefaultTokenTTL, now: time.Now}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+// RandomSecret возвращает случайный ключ подписи. Токены, подписанные им, перестают
+// действовать после перезапуска, поэтому в продакшене ключ нужно задавать явно
+func RandomSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+func (t *Tokens) sign(data string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (t *Tokens) Issue(userID int64) (Token, error) {
+	now := t.now().UTC()
+	expiresAt := now.Add(t.ttl)
+
+	payload, err := json.Marshal(claims{
+		Subject:   strconv.FormatInt(userID, 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return Token{}, err
+	}
+
+	data := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	return Token{Value: data + "." + t.sign(data), ExpiresAt: time.Unix(expiresAt.Unix(), 0).UTC()}, nil
+}
+
+// Parse проверяет токен и возвращает ID пользователя, которому он выдан
+func (t *Tokens) Parse(token string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return 0, ErrInvalidToken
+	}
+
+	data := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(t.sign(data))) {
+		return 0, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	if t.now().Unix() >= c.ExpiresAt {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
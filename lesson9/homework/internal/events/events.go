@@ -0,0 +1,114 @@
+// Package events - внутрипроцессная шина событий об изменениях объявлений
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"homework9/internal/ads"
+)
+
+// ErrLagged - подписчик не успевал читать события, и часть из них была потеряна
+var ErrLagged = errors.New("subscriber lagged behind")
+
+// DefaultBuffer - сколько событий может накопиться у подписчика до его отключения
+const DefaultBuffer = 64
+
+type Kind string
+
+const (
+	AdCreated     Kind = "created"
+	AdUpdated     Kind = "updated"
+	AdPublished   Kind = "published"
+	AdUnpublished Kind = "unpublished"
+	// AdStateChanged - смена состояния, не затрагивающая публикацию, например отправка на проверку
+	AdStateChanged Kind = "state_changed"
+	AdDeleted      Kind = "deleted"
+	AdRestored     Kind = "restored"
+)
+
+// Event описывает изменение объявления: Ad - состояние после изменения,
+// Prev - до изменения (nil для созданного объявления)
+type Event struct {
+	Kind Kind
+	Ad   ads.Ad
+	Prev *ads.Ad
+}
+
+type Subscription struct {
+	bus  *Bus
+	ch   chan Event
+	view func(Event) (Event, bool)
+	err  error
+	once sync.Once
+}
+
+// Events возвращает канал событий, он закрывается после Close или при отставании подписчика
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Err возвращает ErrLagged, если канал был закрыт из-за отставания подписчика
+func (s *Subscription) Err() error {
+	s.bus.mx.RLock()
+	defer s.bus.mx.RUnlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.bus.mx.Lock()
+	defer s.bus.mx.Unlock()
+	s.bus.remove(s, nil)
+}
+
+type Bus struct {
+	mx     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{}), buffer: DefaultBuffer}
+}
+
+// Subscribe подписывает на события, для которых view возвращает true (nil - на все события без изменений).
+// view возвращает событие в том виде, в каком его получит подписчик
+func (b *Bus) Subscribe(view func(Event) (Event, bool)) *Subscription {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	s := &Subscription{bus: b, ch: make(chan Event, b.buffer), view: view}
+	b.subs[s] = struct{}{}
+
+	return s
+}
+
+// Publish рассылает событие подписчикам, не блокируясь: подписчик с заполненным буфером отключается
+func (b *Bus) Publish(e Event) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	for s := range b.subs {
+		se := e
+		if s.view != nil {
+			var ok bool
+			if se, ok = s.view(e); !ok {
+				continue
+			}
+		}
+		select {
+		case s.ch <- se:
+		default:
+			b.remove(s, ErrLagged)
+		}
+	}
+}
+
+// remove отписывает подписчика, вызывается под b.mx
+func (b *Bus) remove(s *Subscription, err error) {
+	s.once.Do(func() {
+		delete(b.subs, s)
+		s.err = err
+		close(s.ch)
+	})
+}
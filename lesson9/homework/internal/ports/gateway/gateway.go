@@ -0,0 +1,157 @@
+// Package gateway - REST API /api/v1, сгенерированное по HTTP-аннотациям service.proto.
+// Запросы транслируются в вызовы gRPC AdService, поэтому REST и gRPC не расходятся
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/ports/grpc/interceptors"
+)
+
+// envelope - формат всех ответов REST API: {"data": ..., "error": ...}
+type envelope struct {
+	Data       json.RawMessage `json:"data"`
+	NextCursor *string         `json:"next_cursor,omitempty"`
+	Error      *string         `json:"error"`
+}
+
+// marshaler заворачивает ответы AdService в envelope, поля называются как в proto
+type marshaler struct {
+	runtime.JSONPb
+}
+
+func newMarshaler() *marshaler {
+	return &marshaler{runtime.JSONPb{
+		MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}}
+}
+
+func (m *marshaler) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return m.JSONPb.Marshal(v)
+	}
+
+	// список (ListAdResponse, AuditLogResponse и другие ответы с полем list) отдается массивом в data,
+	// а курсор, если он есть в ответе, - рядом
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	if list := fields.ByName("list"); list != nil && list.IsList() && list.Message() != nil {
+		items := msg.ProtoReflect().Get(list).List()
+		raw := make([]json.RawMessage, 0, items.Len())
+		for i := 0; i < items.Len(); i++ {
+			item, err := m.JSONPb.Marshal(items.Get(i).Message().Interface())
+			if err != nil {
+				return nil, err
+			}
+			raw = append(raw, item)
+		}
+
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		res := envelope{Data: data}
+		if cursor := fields.ByName("next_cursor"); cursor != nil {
+			nextCursor := msg.ProtoReflect().Get(cursor).String()
+			res.NextCursor = &nextCursor
+		}
+
+		return json.Marshal(res)
+	}
+
+	data, err := m.JSONPb.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{Data: data})
+}
+
+// defaultMarshaler используется и маршрутами httpgin, чтобы все ответы REST API были в одном формате
+var defaultMarshaler = newMarshaler()
+
+// Marshal заворачивает сообщение AdService в envelope
+func Marshal(msg proto.Message) ([]byte, error) {
+	return defaultMarshaler.Marshal(msg)
+}
+
+// httpStatus отличается от runtime.HTTPStatusFromCode там, где у HTTP есть более точный статус
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.FailedPrecondition:
+		// недопустимый переход между состояниями объявления
+		return http.StatusConflict
+	case codes.Aborted:
+		// версия объявления не совпала с If-Match или expected_version
+		return http.StatusPreconditionFailed
+	default:
+		return runtime.HTTPStatusFromCode(code)
+	}
+}
+
+// errorHandler отдает ошибку gRPC в envelope с соответствующим HTTP-статусом
+func errorHandler(_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
+	st := status.Convert(err)
+	message := st.Message()
+
+	body, _ := json.Marshal(envelope{Data: json.RawMessage("null"), Error: &message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	_, _ = w.Write(body)
+}
+
+// forwardETag отдает версию объявления в заголовке ETag, по ней клиент делает условное обновление с If-Match
+func forwardETag(_ context.Context, w http.ResponseWriter, msg proto.Message) error {
+	if ad, ok := msg.(*grpcPort.AdResponse); ok {
+		w.Header().Set("ETag", grpcPort.ETag(ad.Version))
+	}
+	return nil
+}
+
+// headerMatcher пробрасывает в метаданные gRPC идентификатор запроса и If-Match
+func headerMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, interceptors.RequestIDKey) {
+		return interceptors.RequestIDKey, true
+	}
+	if strings.EqualFold(key, grpcPort.IfMatchKey) {
+		return grpcPort.IfMatchKey, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, interceptors.RequestIDKey) {
+		return http.CanonicalHeaderKey(interceptors.RequestIDKey), true
+	}
+	return "", false
+}
+
+// NewHandler возвращает обработчик REST API, который обращается к AdService через client
+func NewHandler(ctx context.Context, client grpcPort.AdServiceClient) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, defaultMarshaler),
+		runtime.WithErrorHandler(errorHandler),
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithForwardResponseOption(forwardETag),
+	)
+
+	if err := grpcPort.RegisterAdServiceHandlerClient(ctx, mux, client); err != nil {
+		return nil, err
+	}
+
+	return mux, nil
+}
@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+
+	"homework9/internal/app"
+)
+
+// IfMatchKey - метаданные с версией объявления для UpdateAd, шлюз заполняет их из заголовка If-Match
+const IfMatchKey = "if-match"
+
+var ErrSeveralETags = errors.New("several entity tags in If-Match are not supported")
+
+// ETag - сильный ETag объявления, меняется вместе с его версией
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// IfMatchVersion возвращает версию объявления из заголовка If-Match, 0 - заголовка нет или он равен "*".
+// Слабые и чужие ETag никогда не совпадают при сильном сравнении, поэтому дают app.ErrVersionMismatch
+func IfMatchVersion(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	versions := map[int64]struct{}{}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+
+		if err != nil || version <= 0 {
+			continue
+		}
+
+		versions[version] = struct{}{}
+	}
+
+	if len(versions) > 1 {
+		return 0, ErrSeveralETags
+	}
+
+	for version := range versions {
+		return version, nil
+	}
+
+	return 0, app.ErrVersionMismatch
+}
+
+// ifMatchVersion - IfMatchVersion для метаданных вызова
+func ifMatchVersion(ctx context.Context) (int64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return IfMatchVersion(strings.Join(md.Get(IfMatchKey), ","))
+}
@@ -0,0 +1,72 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/auth"
+	"homework9/internal/users"
+)
+
+// AuthorizationKey - ключ метаданных с токеном доступа в виде "Bearer <token>"
+const AuthorizationKey = "authorization"
+
+// Authenticator находит пользователя по токену доступа, его реализует app.App
+type Authenticator interface {
+	Authenticate(context.Context, string) (*users.User, error)
+}
+
+// authenticate сохраняет в контексте пользователя из метаданных authorization.
+// Вызов без токена считается анонимным, вызов с недействительным токеном отклоняется
+func authenticate(ctx context.Context, authenticator Authenticator) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+
+	values := md.Get(AuthorizationKey)
+	if len(values) == 0 {
+		return ctx, nil
+	}
+
+	if !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+	}
+
+	user, err := authenticator.Authenticate(ctx, strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return auth.WithUserID(ctx, user.ID), nil
+}
+
+func AuthUnary(authenticator Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AuthStream(authenticator Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), authenticator)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// WithBearerToken добавляет токен доступа в исходящие метаданные клиента
+func WithBearerToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, AuthorizationKey, "Bearer "+token)
+}
@@ -0,0 +1,15 @@
+// Package interceptors содержит интерсепторы gRPC сервера объявлений:
+// идентификатор запроса, логирование, восстановление после паники и аутентификация
+package interceptors
+
+import "google.golang.org/grpc"
+
+// ServerOptions подключает все интерсепторы в нужном порядке: идентификатор запроса
+// доступен логгеру, паника превращается в ошибку до того, как вызов будет залогирован,
+// а отклоненные из-за токена вызовы тоже попадают в лог
+func ServerOptions(logger Logger, authenticator Authenticator) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(RequestIDUnary(), LoggingUnary(logger), RecoveryUnary(logger), AuthUnary(authenticator)),
+		grpc.ChainStreamInterceptor(RequestIDStream(), LoggingStream(logger), RecoveryStream(logger), AuthStream(authenticator)),
+	}
+}
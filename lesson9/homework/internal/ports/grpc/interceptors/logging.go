@@ -0,0 +1,43 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Logger - логгер интерсепторов, подходит *log.Logger
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+func logCall(logger Logger, ctx context.Context, method string, start time.Time, err error) {
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+
+	logger.Printf("method=%s code=%s latency=%s peer=%s request_id=%s",
+		method, status.Code(err), time.Since(start), addr, RequestIDFromContext(ctx))
+}
+
+func LoggingUnary(logger Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(logger, ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func LoggingStream(logger Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(logger, ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
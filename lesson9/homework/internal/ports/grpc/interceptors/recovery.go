@@ -0,0 +1,39 @@
+package interceptors
+
+import (
+	"context"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recovered логирует панику вместе со стеком и превращает ее в codes.Internal,
+// подробности паники клиенту не отдаются
+func recovered(logger Logger, ctx context.Context, method string, p any) error {
+	logger.Printf("panic in %s request_id=%s: %v\n%s", method, RequestIDFromContext(ctx), p, debug.Stack())
+	return status.Error(codes.Internal, "internal error")
+}
+
+func RecoveryUnary(logger Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(logger, ctx, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func RecoveryStream(logger Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(logger, ss.Context(), info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
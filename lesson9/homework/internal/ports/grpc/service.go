@@ -0,0 +1,655 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"homework9/internal/ads"
+	"homework9/internal/app"
+	"homework9/internal/audit"
+	"homework9/internal/auth"
+	"homework9/internal/events"
+	"homework9/internal/users"
+)
+
+type AdService struct {
+	UnimplementedAdServiceServer
+	app app.App
+}
+
+func NewService(a app.App) *AdService {
+	return &AdService{app: a}
+}
+
+// toStatus переводит ошибки приложения в коды gRPC
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, app.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, app.ErrStatusForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, app.ErrNotValid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, app.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, app.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, app.ErrInvalidTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, app.ErrVersionMismatch):
+		// Aborted, а не FailedPrecondition: клиенту нужно перечитать объявление и повторить изменение
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, app.ErrTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, app.ErrUnsupportedMediaType):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrSeveralETags):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, app.ErrNoBlobStore):
+		return status.Error(codes.Unimplemented, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// timestamp переводит нулевое время в незаполненное поле
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// fromTimestamp переводит незаполненное поле в нулевое время
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// AdResponseOf, UserResponseOf и AttachmentOf переводят сущности приложения в сообщения AdService,
+// в том числе для маршрутов httpgin, чтобы REST отвечал в одном формате
+func AdResponseOf(ad *ads.Ad) *AdResponse {
+	return &AdResponse{
+		Id:              ad.ID,
+		Title:           ad.Title,
+		Text:            ad.Text,
+		AuthorId:        ad.AuthorID,
+		Published:       ad.Published(),
+		CreationDate:    timestamp(ad.CreationDate),
+		UpdateDate:      timestamp(ad.UpdateDate),
+		DeletedAt:       timestamp(ad.DeletedAt),
+		State:           string(ad.State),
+		RejectionReason: ad.RejectionReason,
+		Version:         ad.Version,
+		Attachments:     attachmentsResponse(ad.Attachments),
+		Price:           ad.Price,
+		Currency:        ad.Currency,
+		Category:        ad.Category,
+		Location:        locationResponse(ad.Location),
+	}
+}
+
+func locationResponse(location ads.Location) *Location {
+	if location.IsZero() {
+		return nil
+	}
+	return &Location{Region: location.Region, City: location.City}
+}
+
+func createDetails(req *CreateAdRequest) ads.Details {
+	return ads.Details{
+		Price:    req.Price,
+		Currency: req.Currency,
+		Category: req.Category,
+		Location: ads.Location{Region: req.Location.GetRegion(), City: req.Location.GetCity()},
+	}
+}
+
+func AttachmentOf(attachment *ads.Attachment) *Attachment {
+	return &Attachment{
+		Id:           attachment.ID,
+		Name:         attachment.Name,
+		ContentType:  attachment.ContentType,
+		Size:         attachment.Size,
+		CreationDate: timestamp(attachment.CreationDate),
+	}
+}
+
+func attachmentsResponse(attachments []ads.Attachment) []*Attachment {
+	if len(attachments) == 0 {
+		return nil
+	}
+	res := make([]*Attachment, 0, len(attachments))
+	for i := range attachments {
+		res = append(res, AttachmentOf(&attachments[i]))
+	}
+	return res
+}
+
+func listAdResponse(list []*ads.Ad, nextCursor string) *ListAdResponse {
+	res := &ListAdResponse{List: make([]*AdResponse, 0, len(list)), NextCursor: nextCursor}
+	for _, ad := range list {
+		res.List = append(res.List, AdResponseOf(ad))
+	}
+	return res
+}
+
+func auditLogResponse(entries []*audit.Entry, nextCursor string) *AuditLogResponse {
+	res := &AuditLogResponse{List: make([]*AuditEntry, 0, len(entries)), NextCursor: nextCursor}
+	for _, entry := range entries {
+		changes := make([]*AuditChange, 0, len(entry.Changes))
+		for _, change := range entry.Changes {
+			changes = append(changes, &AuditChange{Field: change.Field, Before: change.Before, After: change.After})
+		}
+
+		res.List = append(res.List, &AuditEntry{
+			Id:         entry.ID,
+			ActorId:    entry.ActorID,
+			Action:     entry.Action,
+			Resource:   string(entry.Resource),
+			ResourceId: entry.ResourceID,
+			Changes:    changes,
+			Date:       timestamp(entry.Date),
+		})
+	}
+	return res
+}
+
+// maskPaths возвращает пути маски. Пустая маска, как в AIP-134, означает поля из fields, заданные в req:
+// непустые скаляры и присутствующие сообщения. Путь, которого нет среди fields, - ошибка клиента
+func maskPaths(mask *fieldmaskpb.FieldMask, req proto.Message, fields ...string) (map[string]bool, error) {
+	paths := map[string]bool{}
+
+	if len(mask.GetPaths()) == 0 {
+		msg := req.ProtoReflect()
+		for _, field := range fields {
+			fd := msg.Descriptor().Fields().ByName(protoreflect.Name(field))
+			paths[field] = fd != nil && msg.Has(fd)
+		}
+		return paths, nil
+	}
+
+	for _, path := range mask.GetPaths() {
+		known := false
+		for _, field := range fields {
+			known = known || path == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown update_mask path %q: %w", path, app.ErrNotValid)
+		}
+		paths[path] = true
+	}
+
+	return paths, nil
+}
+
+// adPatch собирает изменение объявления по маске. Без маски меняются только заданные в запросе поля,
+// поэтому клиенты, не знающие о цене, категории и месте, их не сбрасывают
+func adPatch(req *UpdateAdRequest) (app.AdPatch, error) {
+	paths, err := maskPaths(req.UpdateMask, req, "title", "text", "price", "currency", "category", "location")
+	if err != nil {
+		return app.AdPatch{}, err
+	}
+
+	patch := app.AdPatch{}
+	if paths["title"] {
+		patch.Title = app.Value(req.Title)
+	}
+	if paths["text"] {
+		patch.Text = app.Value(req.Text)
+	}
+	if paths["price"] {
+		patch.Price = app.Value(req.Price)
+	}
+	if paths["currency"] {
+		patch.Currency = app.Value(req.Currency)
+	}
+	if paths["category"] {
+		patch.Category = app.Value(req.Category)
+	}
+	if paths["location"] {
+		patch.Region = app.Value(req.Location.GetRegion())
+		patch.City = app.Value(req.Location.GetCity())
+	}
+
+	return patch, nil
+}
+
+func userPatch(req *UpdateUserRequest) (app.UserPatch, error) {
+	paths, err := maskPaths(req.UpdateMask, req, "name", "email")
+	if err != nil {
+		return app.UserPatch{}, err
+	}
+
+	patch := app.UserPatch{}
+	if paths["name"] {
+		patch.Nickname = app.Value(req.Name)
+	}
+	if paths["email"] {
+		patch.Email = app.Value(req.Email)
+	}
+
+	return patch, nil
+}
+
+func UserResponseOf(user *users.User) *UserResponse {
+	return &UserResponse{
+		Id:    user.ID,
+		Name:  user.Nickname,
+		Email: user.Email,
+		Role:  string(user.Role),
+	}
+}
+
+func (s *AdService) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	var token auth.Token
+	var err error
+	if req.Email != "" {
+		token, err = s.app.LoginByEmail(ctx, req.Email, req.Password)
+	} else {
+		token, err = s.app.Login(ctx, req.UserId, req.Password)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &LoginResponse{Token: token.Value, ExpiresAt: timestamp(token.ExpiresAt)}, nil
+}
+
+func (s *AdService) CreateAd(ctx context.Context, req *CreateAdRequest) (*AdResponse, error) {
+	ad, err := s.app.CreateAd(ctx, req.Title, req.Text, createDetails(req))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+func (s *AdService) TransitionAd(ctx context.Context, req *TransitionAdRequest) (*AdResponse, error) {
+	ad, err := s.app.TransitionAd(ctx, req.AdId, ads.State(req.State), req.Reason)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+func (s *AdService) ListAdTransitions(ctx context.Context, req *ListAdTransitionsRequest) (*ListAdTransitionsResponse, error) {
+	transitions, err := s.app.AdTransitions(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	res := &ListAdTransitionsResponse{List: make([]*AdTransition, 0, len(transitions))}
+	for _, transition := range transitions {
+		res.List = append(res.List, &AdTransition{
+			From:    string(transition.From),
+			To:      string(transition.To),
+			ActorId: transition.ActorID,
+			Reason:  transition.Reason,
+			Date:    timestamp(transition.Date),
+		})
+	}
+
+	return res, nil
+}
+
+func (s *AdService) GetAdHistory(ctx context.Context, req *GetAdHistoryRequest) (*AuditLogResponse, error) {
+	entries, err := s.app.AdHistory(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return auditLogResponse(entries, ""), nil
+}
+
+func (s *AdService) ListAuditLog(ctx context.Context, req *ListAuditLogRequest) (*AuditLogResponse, error) {
+	entries, nextCursor, err := s.app.AuditLog(ctx, int(req.Limit), req.Cursor)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return auditLogResponse(entries, nextCursor), nil
+}
+
+func (s *AdService) UpdateAd(ctx context.Context, req *UpdateAdRequest) (*AdResponse, error) {
+	patch, err := adPatch(req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	version := req.ExpectedVersion
+	if version == 0 {
+		if version, err = ifMatchVersion(ctx); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	ad, err := s.app.PatchAd(ctx, req.AdId, patch, version)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+func (s *AdService) GetAd(ctx context.Context, req *GetAdRequest) (*AdResponse, error) {
+	ad, err := s.app.GetAdByID(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+func listOptions(req *ListAdsRequest) []app.FilterOption {
+	options := []app.FilterOption{
+		app.WithPublishedAfter(fromTimestamp(req.PublishedAfter)),
+		app.WithPublishedBefore(fromTimestamp(req.PublishedBefore)),
+		app.WithUpdatedAfter(fromTimestamp(req.UpdatedAfter)),
+		app.WithUpdatedBefore(fromTimestamp(req.UpdatedBefore)),
+		app.WithTitleContains(req.Title),
+		app.WithLimit(int(req.Limit)),
+		app.WithCursor(req.Cursor),
+		app.WithCurrency(req.Currency),
+		app.WithCategory(req.Category),
+		app.WithRegion(req.Region),
+		app.WithCity(req.City),
+	}
+	if req.AuthorId != nil {
+		options = append(options, app.WithAuthorID(*req.AuthorId))
+	}
+	if req.MinPrice != nil {
+		options = append(options, app.WithMinPrice(*req.MinPrice))
+	}
+	if req.MaxPrice != nil {
+		options = append(options, app.WithMaxPrice(*req.MaxPrice))
+	}
+	if req.Status != "" {
+		options = append(options, app.WithStatus(app.PublishStatus(req.Status)))
+	}
+	sortBy := app.SortByCreationDate
+	if req.Sort != "" {
+		sortBy = app.SortField(req.Sort)
+	}
+	return append(options, app.WithSort(sortBy, req.Descending))
+}
+
+// ListAds возвращает страницу объявлений по фильтру, пустой список ошибкой не считается
+func (s *AdService) ListAds(ctx context.Context, req *ListAdsRequest) (*ListAdResponse, error) {
+	list, nextCursor, err := s.app.FilterAds(ctx, listOptions(req)...)
+	if err != nil && !errors.Is(err, app.ErrNotFound) {
+		return nil, toStatus(err)
+	}
+
+	return listAdResponse(list, nextCursor), nil
+}
+
+// SearchAds ищет опубликованные объявления по словам запроса, пустой результат ошибкой не считается
+func (s *AdService) SearchAds(ctx context.Context, req *SearchAdsRequest) (*ListAdResponse, error) {
+	list, err := s.app.SearchAds(ctx, req.Query, int(req.Limit))
+	if err != nil && !errors.Is(err, app.ErrNotFound) {
+		return nil, toStatus(err)
+	}
+
+	return listAdResponse(list, ""), nil
+}
+
+func (s *AdService) SearchAdByName(ctx context.Context, req *SearchAdByNameRequest) (*AdResponse, error) {
+	ad, err := s.app.SearchAdByName(ctx, req.Title)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+func (s *AdService) RestoreAd(ctx context.Context, req *RestoreAdRequest) (*AdResponse, error) {
+	ad, err := s.app.RestoreAd(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+func (s *AdService) CreateUser(ctx context.Context, req *CreateUserRequest) (*UserResponse, error) {
+	user, err := s.app.CreateUser(ctx, req.Name, req.Email, req.Password)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return UserResponseOf(user), nil
+}
+
+func (s *AdService) GetUser(ctx context.Context, req *GetUserRequest) (*UserResponse, error) {
+	user, err := s.app.GetUserByID(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return UserResponseOf(user), nil
+}
+
+func (s *AdService) UpdateUser(ctx context.Context, req *UpdateUserRequest) (*UserResponse, error) {
+	patch, err := userPatch(req)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	user, err := s.app.PatchUser(ctx, req.Id, patch)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return UserResponseOf(user), nil
+}
+
+func (s *AdService) DeleteUser(ctx context.Context, req *DeleteUserRequest) (*UserResponse, error) {
+	user, err := s.app.DeleteUser(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return UserResponseOf(user), nil
+}
+
+func (s *AdService) SetUserRole(ctx context.Context, req *SetUserRoleRequest) (*UserResponse, error) {
+	user, err := s.app.SetUserRole(ctx, req.Id, users.Role(req.Role))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return UserResponseOf(user), nil
+}
+
+func (s *AdService) DeleteAd(ctx context.Context, req *DeleteAdRequest) (*AdResponse, error) {
+	ad, err := s.app.DeleteAd(ctx, req.AdId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
+
+var eventKinds = map[events.Kind]AdEvent_Kind{
+	events.AdCreated:      AdEvent_CREATED,
+	events.AdUpdated:      AdEvent_UPDATED,
+	events.AdPublished:    AdEvent_PUBLISHED,
+	events.AdUnpublished:  AdEvent_UNPUBLISHED,
+	events.AdDeleted:      AdEvent_DELETED,
+	events.AdRestored:     AdEvent_RESTORED,
+	events.AdStateChanged: AdEvent_STATE_CHANGED,
+}
+
+// WatchAds отправляет события до отмены запроса клиентом. Если клиент не успевает читать события,
+// стрим завершается с codes.ResourceExhausted, и клиенту нужно переподписаться
+func (s *AdService) WatchAds(req *WatchAdsRequest, stream AdService_WatchAdsServer) error {
+	options := []app.FilterOption{}
+	if req.AuthorId != nil {
+		options = append(options, app.WithAuthorID(*req.AuthorId))
+	}
+	if req.Status != "" {
+		options = append(options, app.WithStatus(app.PublishStatus(req.Status)))
+	}
+	if req.Title != "" {
+		options = append(options, app.WithTitleContains(req.Title))
+	}
+
+	sub, err := s.app.WatchAds(stream.Context(), options...)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer sub.Close()
+
+	// заголовки отправляются после подписки: получив их, клиент не пропустит ни одного события
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for e := range sub.Events() {
+		ad := e.Ad
+		if err := stream.Send(&AdEvent{Kind: eventKinds[e.Kind], Ad: AdResponseOf(&ad)}); err != nil {
+			return err
+		}
+	}
+
+	if err := sub.Err(); err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	return status.FromContextError(stream.Context().Err()).Err()
+}
+
+// ImportAds создает объявления из стрима по одному: ошибка в одном объявлении
+// попадает в сводку и не прерывает импорт остальных. Анонимный стрим отклоняется целиком
+func (s *AdService) ImportAds(stream AdService_ImportAdsServer) error {
+	if _, ok := auth.UserIDFromContext(stream.Context()); !ok {
+		return toStatus(app.ErrUnauthenticated)
+	}
+
+	summary := &ImportSummary{AdIds: []int64{}, Errors: []*ImportError{}}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(summary)
+		}
+		if err != nil {
+			return err
+		}
+
+		index := summary.Total
+		summary.Total++
+
+		ad, err := s.app.CreateAd(stream.Context(), req.Title, req.Text, createDetails(req))
+		if err != nil {
+			st := status.Convert(toStatus(err))
+			summary.Failed++
+			summary.Errors = append(summary.Errors, &ImportError{Index: index, Code: st.Code().String(), Message: st.Message()})
+			continue
+		}
+
+		summary.Imported++
+		summary.AdIds = append(summary.AdIds, ad.ID)
+	}
+}
+
+// attachmentChunkSize - размер части содержимого в стриме скачивания вложения
+const attachmentChunkSize = 64 << 10
+
+// chunkReader читает содержимое вложения из частей стрима загрузки
+type chunkReader struct {
+	stream AdService_UploadAttachmentServer
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if req.GetInfo() != nil {
+			return 0, fmt.Errorf("%w: info must be sent only once", app.ErrNotValid)
+		}
+		r.buf = req.GetChunk()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+// UploadAttachment ждет первым сообщением описание вложения, а следующими - его содержимое
+func (s *AdService) UploadAttachment(stream AdService_UploadAttachmentServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	info := req.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "first message must contain attachment info")
+	}
+
+	attachment, err := s.app.AddAttachment(stream.Context(), info.AdId, info.Name, &chunkReader{stream: stream})
+	if err != nil {
+		return toStatus(err)
+	}
+
+	return stream.SendAndClose(AttachmentOf(attachment))
+}
+
+// DownloadAttachment отправляет первым сообщением описание вложения, а следующими - его содержимое
+func (s *AdService) DownloadAttachment(req *DownloadAttachmentRequest, stream AdService_DownloadAttachmentServer) error {
+	attachment, content, err := s.app.OpenAttachment(stream.Context(), req.AdId, req.AttachmentId)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer content.Close()
+
+	if err := stream.Send(&DownloadAttachmentResponse{Data: &DownloadAttachmentResponse_Info{Info: AttachmentOf(attachment)}}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, attachmentChunkSize)
+
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			chunk := &DownloadAttachmentResponse{Data: &DownloadAttachmentResponse_Chunk{Chunk: buf[:n]}}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+func (s *AdService) DeleteAttachment(ctx context.Context, req *DeleteAttachmentRequest) (*AdResponse, error) {
+	ad, err := s.app.DeleteAttachment(ctx, req.AdId, req.AttachmentId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return AdResponseOf(ad), nil
+}
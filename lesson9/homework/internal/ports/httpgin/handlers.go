@@ -0,0 +1,250 @@
+package httpgin
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"homework9/internal/app"
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+// Метод для частичного обновления объявления по JSON Merge Patch: меняются только переданные поля
+func patchAd(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patch, err := bindAdPatch(c)
+
+		if errors.Is(err, ErrUnsupportedPatch) {
+			c.JSON(http.StatusUnsupportedMediaType, AdErrorResponse(err))
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		version, err := grpcPort.IfMatchVersion(c.GetHeader("If-Match"))
+
+		if errors.Is(err, app.ErrVersionMismatch) {
+			c.JSON(http.StatusPreconditionFailed, AdErrorResponse(err))
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		ad, err := a.PatchAd(c, adID, patch, version)
+
+		if err != nil {
+			if errors.Is(err, app.ErrVersionMismatch) {
+				c.JSON(http.StatusPreconditionFailed, AdErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrUnauthenticated) {
+				c.JSON(http.StatusUnauthorized, AdErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrNotFound) {
+				c.JSON(http.StatusNotFound, AdErrorResponse(err))
+				return
+			}
+
+			if errors.Is(err, app.ErrStatusForbidden) {
+				c.JSON(http.StatusForbidden, AdErrorResponse(err))
+				return
+			}
+
+			if errors.Is(err, app.ErrNotValid) {
+				c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+				return
+			}
+
+			c.JSON(http.StatusInternalServerError, AdErrorResponse(err))
+			return
+		}
+
+		c.Header("ETag", grpcPort.ETag(ad.Version))
+		protoResponse(c, grpcPort.AdResponseOf(ad))
+	}
+}
+
+// Метод для частичного обновления пользователя по JSON Merge Patch: меняются только переданные поля
+func patchUser(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patch, err := bindUserPatch(c)
+
+		if errors.Is(err, ErrUnsupportedPatch) {
+			c.JSON(http.StatusUnsupportedMediaType, UserErrorResponse(err))
+			return
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, UserErrorResponse(err))
+			return
+		}
+
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, UserErrorResponse(err))
+			return
+		}
+
+		user, err := a.PatchUser(c, userID, patch)
+
+		if err != nil {
+			if errors.Is(err, app.ErrNotValid) {
+				c.JSON(http.StatusBadRequest, UserErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrAlreadyExists) {
+				c.JSON(http.StatusConflict, UserErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrUnauthenticated) {
+				c.JSON(http.StatusUnauthorized, UserErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrStatusForbidden) {
+				c.JSON(http.StatusForbidden, UserErrorResponse(err))
+				return
+			}
+			if errors.Is(err, app.ErrNotFound) {
+				c.JSON(http.StatusNotFound, UserErrorResponse(err))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, UserErrorResponse(err))
+			return
+		}
+
+		protoResponse(c, grpcPort.UserResponseOf(user))
+	}
+}
+
+// maxMultipartOverhead - запас на заголовки частей multipart сверх размера самого вложения
+const maxMultipartOverhead = 64 << 10
+
+var ErrNoFilePart = errors.New("multipart form has no file part")
+
+// attachmentError отвечает на ошибку загрузки, скачивания или удаления вложения
+func attachmentError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, app.ErrUnauthenticated):
+		c.JSON(http.StatusUnauthorized, AdErrorResponse(err))
+	case errors.Is(err, app.ErrNotFound):
+		c.JSON(http.StatusNotFound, AdErrorResponse(err))
+	case errors.Is(err, app.ErrStatusForbidden):
+		c.JSON(http.StatusForbidden, AdErrorResponse(err))
+	case errors.Is(err, app.ErrNotValid):
+		c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+	case errors.Is(err, app.ErrTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, AdErrorResponse(app.ErrTooLarge))
+	case errors.Is(err, app.ErrUnsupportedMediaType):
+		c.JSON(http.StatusUnsupportedMediaType, AdErrorResponse(err))
+	case errors.Is(err, app.ErrNoBlobStore):
+		c.JSON(http.StatusNotImplemented, AdErrorResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, AdErrorResponse(err))
+	}
+}
+
+// Метод для загрузки вложения объявления автором: multipart/form-data с файлом в части file
+func uploadAttachment(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		// без ограничения клиент мог бы слать данные и после того, как вложение уже отклонено по размеру
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, app.MaxAttachmentSize+maxMultipartOverhead)
+
+		// части читаются потоком, а не через ParseMultipartForm, чтобы не копить файл на диске сервера
+		reader, err := c.Request.MultipartReader()
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		for {
+			part, err := reader.NextPart()
+
+			if errors.Is(err, io.EOF) {
+				c.JSON(http.StatusBadRequest, AdErrorResponse(ErrNoFilePart))
+				return
+			}
+
+			if err != nil {
+				attachmentError(c, err)
+				return
+			}
+
+			if part.FormName() != "file" {
+				continue
+			}
+
+			attachment, err := a.AddAttachment(c, adID, part.FileName(), part)
+
+			if err != nil {
+				attachmentError(c, err)
+				return
+			}
+
+			protoResponse(c, grpcPort.AttachmentOf(attachment))
+			return
+		}
+	}
+}
+
+// Метод для скачивания вложения, доступен всем, кому доступно объявление
+func downloadAttachment(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		attachmentID, err := strconv.ParseInt(c.Param("attachment_id"), 10, 64)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, AdErrorResponse(err))
+			return
+		}
+
+		attachment, content, err := a.OpenAttachment(c, adID, attachmentID)
+
+		if err != nil {
+			attachmentError(c, err)
+			return
+		}
+
+		defer content.Close()
+
+		c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}),
+			// браузер не должен угадывать другой тип, чем тот, что проверен при загрузке
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package httpgin
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"homework9/internal/app"
+	"homework9/internal/auth"
+)
+
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		log.Println("Latency", latency, "Request method:", c.Request.Method, "URL path:", c.Request.URL.Path, "Response status:", status, http.StatusText(status))
+	}
+}
+
+// Authenticate сохраняет в контексте запроса пользователя из заголовка Authorization: Bearer <token>.
+// Запрос без заголовка считается анонимным, запрос с недействительным токеном отклоняется
+func Authenticate(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, UserErrorResponse(app.ErrUnauthenticated))
+			return
+		}
+
+		user, err := a.Authenticate(c.Request.Context(), strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, UserErrorResponse(err))
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.WithUserID(c.Request.Context(), user.ID))
+		c.Next()
+	}
+}
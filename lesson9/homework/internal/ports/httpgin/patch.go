@@ -0,0 +1,186 @@
+package httpgin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+
+	"github.com/gin-gonic/gin"
+
+	"homework9/internal/app"
+)
+
+var (
+	ErrUnsupportedPatch = errors.New("patch must be sent as application/merge-patch+json or application/json")
+	ErrPatchNotObject   = errors.New("patch must be a JSON object")
+)
+
+// mergePatch читает тело JSON Merge Patch (RFC 7386). Неизвестные поля игнорируются, как и в PUT
+func mergePatch(c *gin.Context) (map[string]json.RawMessage, error) {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+	if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+		return nil, ErrUnsupportedPatch
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// патч, отличный от объекта, по RFC заменяет ресурс целиком, для этого есть PUT
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return nil, ErrPatchNotObject
+	}
+
+	fields := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// patchString возвращает поле патча: отсутствующее, null или строку
+func patchString(fields map[string]json.RawMessage, name string) (app.Field[string], error) {
+	raw, ok := fields[name]
+
+	if !ok {
+		return app.Field[string]{}, nil
+	}
+
+	if string(raw) == "null" {
+		return app.Null[string](), nil
+	}
+
+	var value string
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return app.Field[string]{}, fmt.Errorf("field %s: %w", name, err)
+	}
+
+	return app.Value(value), nil
+}
+
+// patchInt64 возвращает поле патча: отсутствующее, null или целое число
+func patchInt64(fields map[string]json.RawMessage, name string) (app.Field[int64], error) {
+	raw, ok := fields[name]
+
+	if !ok {
+		return app.Field[int64]{}, nil
+	}
+
+	if string(raw) == "null" {
+		return app.Null[int64](), nil
+	}
+
+	var value int64
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return app.Field[int64]{}, fmt.Errorf("field %s: %w", name, err)
+	}
+
+	return app.Value(value), nil
+}
+
+// patchObject возвращает поля вложенного объекта патча: пустые, если объекта нет, и nil, если он null
+func patchObject(fields map[string]json.RawMessage, name string) (map[string]json.RawMessage, error) {
+	raw, ok := fields[name]
+
+	if !ok {
+		return map[string]json.RawMessage{}, nil
+	}
+
+	if string(raw) == "null" {
+		return nil, nil
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return nil, fmt.Errorf("field %s: %w", name, ErrPatchNotObject)
+	}
+
+	object := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("field %s: %w", name, err)
+	}
+
+	return object, nil
+}
+
+func bindAdPatch(c *gin.Context) (app.AdPatch, error) {
+	fields, err := mergePatch(c)
+
+	if err != nil {
+		return app.AdPatch{}, err
+	}
+
+	var patch app.AdPatch
+
+	if patch.Title, err = patchString(fields, "title"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	if patch.Text, err = patchString(fields, "text"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	if patch.Price, err = patchInt64(fields, "price"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	if patch.Currency, err = patchString(fields, "currency"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	if patch.Category, err = patchString(fields, "category"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	// location сливается с текущим местом по тем же правилам, null сбрасывает его целиком
+	location, err := patchObject(fields, "location")
+
+	if err != nil {
+		return app.AdPatch{}, err
+	}
+
+	if location == nil {
+		patch.Region, patch.City = app.Null[string](), app.Null[string]()
+		return patch, nil
+	}
+
+	if patch.Region, err = patchString(location, "region"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	if patch.City, err = patchString(location, "city"); err != nil {
+		return app.AdPatch{}, err
+	}
+
+	return patch, nil
+}
+
+func bindUserPatch(c *gin.Context) (app.UserPatch, error) {
+	fields, err := mergePatch(c)
+
+	if err != nil {
+		return app.UserPatch{}, err
+	}
+
+	var patch app.UserPatch
+
+	if patch.Nickname, err = patchString(fields, "name"); err != nil {
+		return app.UserPatch{}, err
+	}
+
+	if patch.Email, err = patchString(fields, "email"); err != nil {
+		return app.UserPatch{}, err
+	}
+
+	return patch, nil
+}
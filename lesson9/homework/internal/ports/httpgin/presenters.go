@@ -0,0 +1,36 @@
+package httpgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+
+	"homework9/internal/ports/gateway"
+)
+
+// protoResponse отвечает сообщением AdService в том же формате, что и маршруты шлюза
+func protoResponse(c *gin.Context, msg proto.Message) {
+	body, err := gateway.Marshal(msg)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, AdErrorResponse(err))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+func AdErrorResponse(err error) *gin.H {
+	return &gin.H{
+		"data":  nil,
+		"error": err.Error(),
+	}
+}
+
+func UserErrorResponse(err error) *gin.H {
+	return &gin.H{
+		"data":  nil,
+		"error": err.Error(),
+	}
+}
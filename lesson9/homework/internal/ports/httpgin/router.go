@@ -0,0 +1,20 @@
+package httpgin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"homework9/internal/app"
+)
+
+// AppRouter регистрирует маршруты, которые шлюз AdService не транслирует, остальное API /api/v1 обслуживает шлюз
+func AppRouter(r gin.IRouter, a app.App) {
+	r.Use(Logger())                          //Логгер
+	r.Use(gin.Recovery())                    //panic recovery
+	r.Use(Authenticate(a))                   // Пользователь из заголовка Authorization
+	r.PATCH("/ads/:ad_id", patchAd(a))       // Метод для частичного обновления объявления (JSON Merge Patch)
+	r.PATCH("/users/:user_id", patchUser(a)) // Метод для частичного обновления пользователя (JSON Merge Patch)
+
+	// Вложения объявлений
+	r.POST("/ads/:ad_id/attachments", uploadAttachment(a))                 // Метод для загрузки вложения автором (multipart/form-data, часть file)
+	r.GET("/ads/:ad_id/attachments/:attachment_id", downloadAttachment(a)) // Метод для скачивания вложения
+}
@@ -7,12 +7,22 @@ import (
 	"homework9/internal/app"
 )
 
-func NewHTTPServer(port string, a app.App) *http.Server {
+// NewHTTPServer обслуживает /api/v1: маршруты AppRouter - сам, остальные запросы передает gateway,
+// REST-шлюзу AdService (см. ports/gateway)
+func NewHTTPServer(port string, a app.App, gateway http.Handler) *http.Server {
 	gin.SetMode(gin.ReleaseMode)
 	handler := gin.New()
+	// пользователь, сохраненный middleware в контексте запроса, должен быть виден через *gin.Context
+	handler.ContextWithFallback = true
+	handler.NoRoute(func(c *gin.Context) {
+		// gin выставляет 404 до вызова NoRoute, а шлюз не пишет статус 200 явно
+		c.Status(http.StatusOK)
+		gateway.ServeHTTP(c.Writer, c.Request)
+	})
 	s := &http.Server{Addr: port, Handler: handler}
 
-	// todo: add your own logic
+	api := handler.Group("/api/v1")
+	AppRouter(api, a)
 
 	return s
 }
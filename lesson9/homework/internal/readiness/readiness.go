@@ -0,0 +1,32 @@
+package readiness
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Flag - признак готовности сервиса принимать новые запросы.
+// При остановке флаг снимается до начала ожидания текущих запросов,
+// чтобы балансировщик успел перестать направлять трафик
+type Flag struct {
+	ready atomic.Bool
+}
+
+func (f *Flag) Set(ready bool) {
+	f.ready.Store(ready)
+}
+
+func (f *Flag) Ready() bool {
+	return f.ready.Load()
+}
+
+// Handler отвечает 200, пока сервис готов, и 503 в остальное время
+func (f *Flag) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,356 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/adapters/blobfs"
+	"homework9/internal/adapters/blobmem"
+	"homework9/internal/app"
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+// pngContent - содержимое, которое определяется как image/png по сигнатуре
+func pngContent(size int) []byte {
+	content := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, size)...)
+	return content
+}
+
+func TestUploadAttachment(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	content := pngContent(100)
+
+	attachment, err := client.uploadAttachment(0, ad.Data.ID, "../photos/cat.png", content)
+	assert.NoError(t, err)
+	assert.Equal(t, "cat.png", attachment.Data.Name)
+	assert.Equal(t, "image/png", attachment.Data.ContentType)
+	assert.Equal(t, int64(len(content)), attachment.Data.Size)
+
+	response, err := client.getAdByIDAs(0, ad.Data.ID)
+	assert.NoError(t, err)
+	require.Len(t, response.Data.Attachments, 1)
+	assert.Equal(t, attachment.Data.ID, response.Data.Attachments[0].ID)
+
+	// в списках вложения не загружаются
+	require.NoError(t, approveAd(client.repo, ad.Data.ID))
+	list, err := client.listAds()
+	assert.NoError(t, err)
+	require.NotEmpty(t, list.Data)
+	for _, item := range list.Data {
+		assert.Empty(t, item.Attachments)
+	}
+
+	downloaded, contentType, err := client.downloadAttachment(0, ad.Data.ID, attachment.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	assert.Equal(t, content, downloaded)
+
+	history, err := client.adHistory(0, ad.Data.ID)
+	assert.NoError(t, err)
+	require.NotEmpty(t, history.Data)
+	assert.Equal(t, string(app.ActionAddAttachment), history.Data[len(history.Data)-1].Action)
+}
+
+func TestUploadAttachment_NotValid(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	// тип определяется по содержимому, а не по имени файла
+	_, err = client.uploadAttachment(0, ad.Data.ID, "cat.png", []byte("just text"))
+	assert.ErrorIs(t, err, ErrUnsupportedMedia)
+
+	_, err = client.uploadAttachment(0, ad.Data.ID, "empty.png", nil)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.uploadAttachment(0, ad.Data.ID, "big.png", pngContent(app.MaxAttachmentSize))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = client.uploadAttachment(1, ad.Data.ID, "cat.png", pngContent(100))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.uploadAttachment(0, 100, "cat.png", pngContent(100))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// отклоненные загрузки не оставляют вложений
+	response, err := client.getAdByIDAs(0, ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Data.Attachments)
+}
+
+func TestUploadAttachment_Limit(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	for i := 0; i < app.MaxAttachments; i++ {
+		_, err = client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+		require.NoError(t, err)
+	}
+
+	_, err = client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	attachment, err := client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+	require.NoError(t, err)
+
+	_, err = client.deleteAttachment(1, ad.Data.ID, attachment.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	response, err := client.deleteAttachment(0, ad.Data.ID, attachment.Data.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Data.Attachments)
+
+	_, _, err = client.downloadAttachment(0, ad.Data.ID, attachment.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.deleteAttachment(0, ad.Data.ID, attachment.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAttachments_Version(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	etag, err := client.getAdETag(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	// вложения входят в объявление, поэтому меняют его версию и ETag
+	attachment, err := client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+	require.NoError(t, err)
+
+	_, err = client.updateAdIfMatch(0, ad.Data.ID, "hi", "world", etag)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	response, err := client.getAdByIDAs(0, ad.Data.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ad.Data.Version+1, response.Data.Version)
+
+	response, err = client.deleteAttachment(0, ad.Data.ID, attachment.Data.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ad.Data.Version+2, response.Data.Version)
+}
+
+func TestDeleteAd_Attachments(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	attachment, err := client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+	require.NoError(t, err)
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	// вложения удаленного объявления недоступны
+	_, _, err = client.downloadAttachment(0, ad.Data.ID, attachment.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPurgeDeletedAds_Attachments(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	store := blobmem.New().(*blobmem.Store)
+	repo := adrepo.New()
+	a := newTestApp(repo, app.WithClock(clock.Now), app.WithRetention(time.Hour), app.WithBlobStore(store))
+	client := getTestClientWithApp(repo, a)
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+		require.NoError(t, err)
+	}
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	// пока объявление можно восстановить, содержимое вложений хранится
+	assert.Equal(t, 2, store.Len())
+
+	clock.Advance(2 * time.Hour)
+
+	purged, err := a.PurgeDeletedAds(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestDeleteUser_Attachments(t *testing.T) {
+	store := blobmem.New().(*blobmem.Store)
+	repo := adrepo.New()
+	client := getTestClientWithApp(repo, newTestApp(repo, app.WithBlobStore(store)))
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.uploadAttachment(0, ad.Data.ID, "cat.png", pngContent(10))
+	require.NoError(t, err)
+
+	deleted, err := client.createAd(0, "deleted", "ad")
+	require.NoError(t, err)
+
+	_, err = client.uploadAttachment(0, deleted.Data.ID, "dog.png", pngContent(10))
+	require.NoError(t, err)
+
+	_, err = client.deleteAd(0, deleted.Data.ID)
+	require.NoError(t, err)
+
+	_, err = client.deleteUser(0)
+	require.NoError(t, err)
+
+	// удаляется содержимое вложений всех объявлений автора, в том числе помеченных удаленными
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestGRPCAttachments(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, user.Id)
+
+	ad, err := client.CreateAd(ctx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+
+	content := pngContent(200 << 10)
+
+	upload, err := client.UploadAttachment(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, upload.Send(&grpcPort.UploadAttachmentRequest{Data: &grpcPort.UploadAttachmentRequest_Info{Info: &grpcPort.AttachmentInfo{AdId: ad.Id, Name: "cat.png"}}}))
+	for rest := content; len(rest) > 0; {
+		n := len(rest)
+		if n > 50<<10 {
+			n = 50 << 10
+		}
+		require.NoError(t, upload.Send(&grpcPort.UploadAttachmentRequest{Data: &grpcPort.UploadAttachmentRequest_Chunk{Chunk: rest[:n]}}))
+		rest = rest[n:]
+	}
+
+	attachment, err := upload.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.Equal(t, int64(len(content)), attachment.Size)
+
+	res, err := client.GetAd(ctx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	require.Len(t, res.Attachments, 1)
+	assert.Equal(t, attachment.Id, res.Attachments[0].Id)
+
+	download, err := client.DownloadAttachment(ctx, &grpcPort.DownloadAttachmentRequest{AdId: ad.Id, AttachmentId: attachment.Id})
+	require.NoError(t, err)
+
+	first, err := download.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "cat.png", first.GetInfo().GetName())
+
+	downloaded := []byte{}
+	for {
+		msg, err := download.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		downloaded = append(downloaded, msg.GetChunk()...)
+	}
+	assert.Equal(t, content, downloaded)
+
+	res, err = client.DeleteAttachment(ctx, &grpcPort.DeleteAttachmentRequest{AdId: ad.Id, AttachmentId: attachment.Id})
+	assert.NoError(t, err)
+	assert.Empty(t, res.Attachments)
+}
+
+func TestGRPCUploadAttachment_NotValid(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, user.Id)
+
+	ad, err := client.CreateAd(ctx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+
+	// первым сообщением должно идти описание вложения
+	upload, err := client.UploadAttachment(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&grpcPort.UploadAttachmentRequest{Data: &grpcPort.UploadAttachmentRequest_Chunk{Chunk: pngContent(10)}}))
+	_, err = upload.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	upload, err = client.UploadAttachment(ctx)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&grpcPort.UploadAttachmentRequest{Data: &grpcPort.UploadAttachmentRequest_Info{Info: &grpcPort.AttachmentInfo{AdId: ad.Id, Name: "notes.txt"}}}))
+	require.NoError(t, upload.Send(&grpcPort.UploadAttachmentRequest{Data: &grpcPort.UploadAttachmentRequest_Chunk{Chunk: []byte("just text")}}))
+	_, err = upload.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestBlobFS(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := blobfs.New(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "key", bytes.NewReader([]byte("content"))))
+
+	r, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "content", string(content))
+
+	// ключ не может указывать за пределы каталога
+	assert.ErrorIs(t, store.Put(ctx, "../key", bytes.NewReader(nil)), blobfs.ErrInvalidKey)
+
+	assert.NoError(t, store.Delete(ctx, "key"))
+
+	_, err = store.Get(ctx, "key")
+	assert.ErrorIs(t, err, blobfs.ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "key"), blobfs.ErrNotFound)
+}
@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/app"
+	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/users"
+)
+
+func auditActions(entries []auditData) []string {
+	actions := []string{}
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+func TestAdHistory(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.updateAd(0, ad.Data.ID, "hello", "everyone")
+	require.NoError(t, err)
+
+	_, err = client.transitionAd(0, ad.Data.ID, "pending", "")
+	require.NoError(t, err)
+
+	_, err = client.transitionAd(1, ad.Data.ID, "rejected", "no photos")
+	require.NoError(t, err)
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	// история удаленного объявления остается доступной
+	history, err := client.adHistory(0, ad.Data.ID)
+	assert.NoError(t, err)
+	require.Len(t, history.Data, 5)
+	assert.Equal(t, []string{"create_ad", "update_ad", "submit_ad", "reject_ad", "delete_ad"}, auditActions(history.Data))
+
+	created := history.Data[0]
+	assert.Equal(t, int64(0), created.ActorID)
+	assert.Equal(t, "ad", created.Resource)
+	assert.Equal(t, ad.Data.ID, created.ResourceID)
+	assert.Contains(t, created.Changes, auditChangeData{Field: "title", After: "hello"})
+	assert.False(t, created.Date.IsZero())
+
+	assert.Equal(t, []auditChangeData{{Field: "text", Before: "world", After: "everyone"}}, history.Data[1].Changes)
+
+	rejected := history.Data[3]
+	assert.Equal(t, int64(1), rejected.ActorID)
+	assert.Contains(t, rejected.Changes, auditChangeData{Field: "state", Before: "pending", After: "rejected"})
+	assert.Contains(t, rejected.Changes, auditChangeData{Field: "rejection_reason", After: "no photos"})
+
+	require.Len(t, history.Data[4].Changes, 1)
+	assert.Equal(t, "deleted_at", history.Data[4].Changes[0].Field)
+
+	_, err = client.adHistory(1, ad.Data.ID)
+	assert.NoError(t, err)
+
+	other, err := client.createUser("Dob", "dob@box.com")
+	require.NoError(t, err)
+
+	_, err = client.adHistory(other.Data.ID, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.adHistory(0, 100)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAdHistory_FailedChangeNotRecorded(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	// изменение отклонено валидацией, и запись о нем откатывается вместе с транзакцией
+	_, err = client.transitionAd(0, ad.Data.ID, "published", "")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	history, err := client.adHistory(0, ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"create_ad"}, auditActions(history.Data))
+}
+
+func TestAuditLog(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	bob, err := client.updateUser(0, "Bobby", "Bob@box.com")
+	require.NoError(t, err)
+
+	_, err = client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.auditLog(0, nil)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.auditLog(1, nil)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	log, err := client.auditLog(2, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, log.NextCursor)
+	// журнал сервиса идет от новых записей к старым
+	assert.Equal(t, []string{"create_ad", "update_user", "set_user_role", "create_user", "create_user", "create_user"}, auditActions(log.Data))
+
+	renamed := log.Data[1]
+	assert.Equal(t, "user", renamed.Resource)
+	assert.Equal(t, bob.Data.ID, renamed.ResourceID)
+	assert.Equal(t, []auditChangeData{{Field: "nickname", Before: "Bob", After: "Bobby"}}, renamed.Changes)
+
+	role := log.Data[2]
+	assert.Equal(t, int64(2), role.ActorID)
+	assert.Equal(t, []auditChangeData{{Field: "role", Before: "user", After: "moderator"}}, role.Changes)
+
+	// хеш пароля в журнал не попадает
+	for _, change := range log.Data[5].Changes {
+		assert.NotContains(t, change.Field, "password")
+	}
+}
+
+func TestAuditLog_Pagination(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	for i := 0; i < 4; i++ {
+		_, err := client.createAd(0, "hello", "world")
+		require.NoError(t, err)
+	}
+
+	ids := []int64{}
+	query := url.Values{"limit": {"3"}}
+
+	for {
+		log, err := client.auditLog(2, query)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(log.Data), 3)
+
+		for _, entry := range log.Data {
+			ids = append(ids, entry.ID)
+		}
+
+		if log.NextCursor == "" {
+			break
+		}
+
+		query.Set("cursor", log.NextCursor)
+	}
+
+	// 3 регистрации, назначение модератора и 4 объявления
+	require.Len(t, ids, 8)
+	for i := 1; i < len(ids); i++ {
+		assert.Greater(t, ids[i-1], ids[i])
+	}
+
+	_, err := client.auditLog(2, url.Values{"cursor": {"not a cursor"}})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.auditLog(2, url.Values{"limit": {"-1"}})
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestAuditLog_DeleteUser(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	deleted, err := client.createAd(0, "deleted", "ad")
+	require.NoError(t, err)
+
+	_, err = client.deleteAd(0, deleted.Data.ID)
+	require.NoError(t, err)
+
+	_, err = client.deleteUserAs(2, 0)
+	require.NoError(t, err)
+
+	// журнал переживает удаление автора вместе с его объявлениями, в том числе помеченными удаленными
+	log, err := client.auditLog(2, url.Values{"limit": {"3"}})
+	assert.NoError(t, err)
+	require.Len(t, log.Data, 3)
+
+	assert.Equal(t, "delete_user", log.Data[0].Action)
+	assert.Equal(t, "user", log.Data[0].Resource)
+	assert.Contains(t, log.Data[0].Changes, auditChangeData{Field: "nickname", Before: "Bob"})
+
+	assert.Equal(t, "delete_user", log.Data[1].Action)
+	assert.Equal(t, "ad", log.Data[1].Resource)
+	assert.Equal(t, deleted.Data.ID, log.Data[1].ResourceID)
+
+	assert.Equal(t, "delete_user", log.Data[2].Action)
+	assert.Equal(t, "ad", log.Data[2].Resource)
+	assert.Equal(t, ad.Data.ID, log.Data[2].ResourceID)
+	assert.Equal(t, int64(2), log.Data[2].ActorID)
+
+	// помеченное удаленным объявление удалено окончательно, и восстановить его нельзя
+	_, err = client.restoreAd(1, deleted.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAuditLog_Purge(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	repo := adrepo.New()
+	a := newTestApp(repo, app.WithClock(clock.Now), app.WithRetention(time.Hour))
+	client := getTestClientWithApp(repo, a)
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Root", "root@box.com")
+	require.NoError(t, repo.UpdateUserRole(context.Background(), 1, users.RoleAdmin))
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	clock.Advance(2 * time.Hour)
+
+	purged, err := a.PurgeDeletedAds(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+
+	_, err = a.PurgeDeletedAds(context.Background())
+	require.NoError(t, err)
+
+	log, err := client.auditLog(1, nil)
+	assert.NoError(t, err)
+	require.NotEmpty(t, log.Data)
+
+	// пустая очистка в журнал не попадает
+	assert.Equal(t, "purge_ads", log.Data[0].Action)
+	assert.Equal(t, int64(-1), log.Data[0].ActorID)
+	assert.Equal(t, []auditChangeData{{Field: "purged", After: "1"}}, log.Data[0].Changes)
+	assert.Equal(t, "delete_ad", log.Data[1].Action)
+}
+
+func TestGRPCAuditLog(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	require.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+
+	_, err = client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "hi", Text: "world"})
+	require.NoError(t, err)
+
+	history, err := client.GetAdHistory(bobCtx, &grpcPort.GetAdHistoryRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	require.Len(t, history.List, 2)
+	assert.Equal(t, "update_ad", history.List[1].Action)
+	require.Len(t, history.List[1].Changes, 1)
+	assert.Equal(t, "hi", history.List[1].Changes[0].After)
+
+	_, err = client.ListAuditLog(bobCtx, &grpcPort.ListAuditLogRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	require.NoError(t, repo.UpdateUserRole(context.Background(), bob.Id, users.RoleAdmin))
+
+	log, err := client.ListAuditLog(bobCtx, &grpcPort.ListAuditLogRequest{Limit: 2})
+	assert.NoError(t, err)
+	require.Len(t, log.List, 2)
+	assert.NotEmpty(t, log.NextCursor)
+
+	log, err = client.ListAuditLog(bobCtx, &grpcPort.ListAuditLogRequest{Limit: 2, Cursor: log.NextCursor})
+	assert.NoError(t, err)
+	require.Len(t, log.List, 1)
+	assert.Equal(t, "create_user", log.List[0].Action)
+	assert.Empty(t, log.NextCursor)
+
+	_, err = client.ListAuditLog(bobCtx, &grpcPort.ListAuditLogRequest{Cursor: "!"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/app"
+	"homework9/internal/auth"
+	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/ports/grpc/interceptors"
+)
+
+func TestLogin(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("Bob", "bob@box.com")
+	require.NoError(t, err)
+
+	token, err := client.login(user.Data.ID, testPassword)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token.Data.Token)
+	assert.True(t, token.Data.ExpiresAt.After(time.Now()))
+
+	_, err = client.login(user.Data.ID, "wrong")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.login(100, testPassword)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestLoginByEmail(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("Bob", "Bob@box.com")
+	require.NoError(t, err)
+
+	// email сравнивается без учета регистра, как и при проверке уникальности
+	token, err := client.loginByEmail("bob@box.com", testPassword)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token.Data.Token)
+
+	_, err = client.loginByEmail("bob@box.com", "wrong")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.loginByEmail("dob@box.com", testPassword)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestCreateUserWithoutPassword(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUserWithPassword("Bob", "bob@box.com", "")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestInvalidToken(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("Bob", "bob@box.com")
+	require.NoError(t, err)
+
+	client.tokens[0] = "not-a-token"
+	_, err = client.createAd(0, "hello", "world")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	req, err := http.NewRequest(http.MethodGet, client.baseURL+"/api/v1/ads", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Basic Ym9iOnBhc3N3b3Jk")
+
+	var response adsResponse
+	assert.ErrorIs(t, client.getResponse(req, &response), ErrUnauthorized)
+}
+
+func TestExpiredToken(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	tokens := auth.NewTokens([]byte("secret"), auth.WithTokenTTL(time.Hour), auth.WithTokenClock(clock.Now))
+	repo := adrepo.New()
+	client := getTestClientWithApp(repo, newTestApp(repo, app.WithTokens(tokens)))
+
+	_, err := client.createUser("Bob", "bob@box.com")
+	require.NoError(t, err)
+
+	_, err = client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	clock.Advance(time.Hour)
+
+	_, err = client.createAd(0, "hello", "world")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestTokenSignedWithAnotherSecret(t *testing.T) {
+	token, err := auth.NewTokens([]byte("secret")).Issue(0)
+	require.NoError(t, err)
+
+	_, err = auth.NewTokens([]byte("another")).Parse(token.Value)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+	userID, err := auth.NewTokens([]byte("secret")).Parse(token.Value)
+	assert.NoError(t, err)
+	assert.Zero(t, userID)
+}
+
+func TestUpdateAnotherUser(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	// от имени Bob пытаемся изменить и удалить Dob
+	client.tokens[1] = client.tokens[0]
+
+	_, err := client.updateUser(1, "Vladimir", "newboss@gmail.com")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.deleteUser(1)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	response, err := client.getUserByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Dob", response.Data.Nickname)
+}
+
+func TestGRPCLogin(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	require.NoError(t, err)
+
+	res, err := client.Login(ctx, &grpcPort.LoginRequest{UserId: bob.Id, Password: testPassword})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, res.Token)
+	assert.NotNil(t, res.ExpiresAt)
+
+	_, err = client.Login(ctx, &grpcPort.LoginRequest{UserId: bob.Id, Password: "wrong"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	res, err = client.Login(ctx, &grpcPort.LoginRequest{Email: "BOB@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, res.Token)
+
+	_, err = client.Login(ctx, &grpcPort.LoginRequest{Email: "bob@box.com", Password: "wrong"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCAuthInterceptor(t *testing.T) {
+	client, ctx, logs := getGRPCClientWithInterceptors(t, newTestApp(adrepo.New()))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	require.NoError(t, err)
+
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+	assert.Equal(t, bob.Id, ad.AuthorId)
+
+	_, err = client.CreateAd(interceptors.WithBearerToken(ctx, "bad"), &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Contains(t, logs.String(), "method=/ad.AdService/CreateAd code=Unauthenticated")
+
+	// черновик анонимному пользователю не виден, автору - виден
+	_, err = client.GetAd(ctx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetAd(bobCtx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteAd(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	response, err := client.deleteAd(0, ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, response.Data.ID, ad.Data.ID)
+	assert.Equal(t, response.Data.Title, "hello")
+
+	_, err = client.getAdByID(ad.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.deleteAd(0, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDeleteAdByNotAuthor(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.deleteAd(1, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.deleteAd(2, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.getAdByIDAs(0, ad.Data.ID)
+	assert.NoError(t, err)
+}
+
+func TestDeletedAdNotSearchable(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ads := createPublishedAds(t, client, "golang course")
+
+	_, err := client.deleteAd(0, ads[0].ID)
+	assert.NoError(t, err)
+
+	empty, err := client.searchAds("golang")
+	assert.NoError(t, err)
+	assert.Empty(t, empty.Data)
+}
+
+func TestDeleteUserCascade(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	bobAd, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	dobAd, err := client.createAd(1, "hello", "world")
+	assert.NoError(t, err)
+
+	response, err := client.deleteUser(0)
+	assert.NoError(t, err)
+	assert.Equal(t, response.Data.Nickname, "Bob")
+
+	_, err = client.getAdByID(bobAd.Data.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.getAdByIDAs(1, dobAd.Data.ID)
+	assert.NoError(t, err)
+
+	// токен удаленного пользователя больше не действует
+	_, err = client.createAd(0, "hello", "world")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	_, err = client.deleteUser(0)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
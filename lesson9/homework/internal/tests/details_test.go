@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"homework9/internal/ads"
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+func TestCreateAd_Details(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	response, err := client.createAdWithDetails(0, map[string]any{
+		"title":    "phone",
+		"text":     "almost new",
+		"price":    1999900,
+		"currency": "USD",
+		"category": "electronics/phones",
+		"location": map[string]any{"region": "Moscow", "city": "Moscow"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1999900), response.Data.Price)
+	assert.Equal(t, "USD", response.Data.Currency)
+	assert.Equal(t, "electronics/phones", response.Data.Category)
+	require.NotNil(t, response.Data.Location)
+	assert.Equal(t, "Moscow", response.Data.Location.City)
+
+	response, err = client.getAdByIDAs(0, response.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1999900), response.Data.Price)
+	assert.Equal(t, "electronics/phones", response.Data.Category)
+
+	// без валюты цена указывается в рублях, место и категория необязательны
+	response, err = client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+	assert.Equal(t, ads.DefaultCurrency, response.Data.Currency)
+	assert.Equal(t, "", response.Data.Category)
+	assert.Nil(t, response.Data.Location)
+}
+
+func TestCreateAd_DetailsNotValid(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	bodies := []map[string]any{
+		{"title": "hello", "text": "world", "price": -1},
+		{"title": "hello", "text": "world", "currency": "usd"},
+		{"title": "hello", "text": "world", "currency": "XXX"},
+		{"title": "hello", "text": "world", "category": "phones"},
+		{"title": "hello", "text": "world", "category": "electronics/"},
+		{"title": "hello", "text": "world", "location": map[string]any{"city": "Moscow"}},
+		{"title": "hello", "text": "world", "location": map[string]any{"region": strings.Repeat("a", 101), "city": "Moscow"}},
+	}
+
+	for _, body := range bodies {
+		_, err := client.createAdWithDetails(0, body)
+		assert.ErrorIs(t, err, ErrBadRequest, body)
+	}
+}
+
+func TestPatchAd_Details(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAdWithDetails(0, map[string]any{
+		"title":    "bike",
+		"text":     "red",
+		"price":    500000,
+		"location": map[string]any{"region": "Tver Oblast", "city": "Tver"},
+	})
+	require.NoError(t, err)
+
+	// вложенный объект сливается с текущим местом
+	response, err := client.patchAd(0, ad.Data.ID, `{"price": 450000, "location": {"city": "Torzhok"}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(450000), response.Data.Price)
+	require.NotNil(t, response.Data.Location)
+	assert.Equal(t, "Tver Oblast", response.Data.Location.Region)
+	assert.Equal(t, "Torzhok", response.Data.Location.City)
+
+	response, err = client.patchAd(0, ad.Data.ID, `{"category": "transport", "location": null}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "transport", response.Data.Category)
+	assert.Nil(t, response.Data.Location)
+	assert.Equal(t, "bike", response.Data.Title)
+
+	_, err = client.patchAd(0, ad.Data.ID, `{"price": "free"}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchAd(0, ad.Data.ID, `{"location": "Tver"}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchAd(0, ad.Data.ID, `{"currency": null}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// PUT меняет только заголовок и текст
+	response, err = client.updateAd(0, ad.Data.ID, "bicycle", "red")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(450000), response.Data.Price)
+	assert.Equal(t, "transport", response.Data.Category)
+
+	history, err := client.adHistory(0, ad.Data.ID)
+	assert.NoError(t, err)
+	require.Len(t, history.Data, 4)
+	assert.Contains(t, history.Data[1].Changes, auditChangeData{Field: "price", Before: "500000", After: "450000"})
+}
+
+func TestFilterAds_Details(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	bodies := []map[string]any{
+		{"title": "phone", "text": "a", "price": 1000000, "category": "electronics/phones", "location": map[string]any{"region": "Moscow", "city": "Moscow"}},
+		{"title": "laptop", "text": "b", "price": 5000000, "category": "electronics/computers", "location": map[string]any{"region": "Leningrad Oblast", "city": "Gatchina"}},
+		{"title": "car", "text": "c", "price": 50000000, "category": "transport/cars", "location": map[string]any{"region": "Moscow", "city": "Moscow"}},
+		{"title": "guitar", "text": "d", "price": 1500000, "currency": "USD", "category": "hobby/music"},
+	}
+
+	for _, body := range bodies {
+		ad, err := client.createAdWithDetails(0, body)
+		require.NoError(t, err)
+		require.NoError(t, approveAd(client.repo, ad.Data.ID))
+	}
+
+	titles := func(query url.Values) []string {
+		response, err := client.filterAdsQuery(query)
+		if err != nil {
+			assert.ErrorIs(t, err, ErrNotFound)
+			return nil
+		}
+		res := []string{}
+		for _, ad := range response.Data {
+			res = append(res, ad.Title)
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"phone", "laptop"}, titles(url.Values{"category": {"electronics"}}))
+	assert.Equal(t, []string{"laptop"}, titles(url.Values{"category": {"electronics/computers"}}))
+	assert.Equal(t, []string{"phone", "laptop", "guitar"}, titles(url.Values{"max_price": {"5000000"}}))
+	assert.Equal(t, []string{"phone", "laptop"}, titles(url.Values{"max_price": {"5000000"}, "currency": {"RUB"}}))
+	assert.Equal(t, []string{"laptop", "car"}, titles(url.Values{"min_price": {"2000000"}}))
+	assert.Equal(t, []string{"phone", "car"}, titles(url.Values{"region": {"moscow"}}))
+	assert.Equal(t, []string{"laptop"}, titles(url.Values{"city": {"Gatchina"}}))
+	assert.Empty(t, titles(url.Values{"category": {"realty"}}))
+
+	for _, query := range []url.Values{
+		{"category": {"unknown"}},
+		{"currency": {"ABC"}},
+		{"min_price": {"cheap"}},
+		{"min_price": {"100"}, "max_price": {"10"}},
+	} {
+		_, err := client.filterAdsQuery(query)
+		assert.ErrorIs(t, err, ErrBadRequest, query)
+	}
+}
+
+func TestGRPCAdDetails(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, user.Id)
+
+	ad, err := client.CreateAd(ctx, &grpcPort.CreateAdRequest{
+		Title:    "sofa",
+		Text:     "blue",
+		Price:    2000000,
+		Category: "home/furniture",
+		Location: &grpcPort.Location{Region: "Moscow", City: "Moscow"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ads.DefaultCurrency, ad.Currency)
+	assert.Equal(t, "Moscow", ad.Location.GetCity())
+
+	_, err = client.CreateAd(ctx, &grpcPort.CreateAdRequest{Title: "sofa", Text: "blue", Category: "sofas"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// без маски цена, категория и место не сбрасываются
+	res, err := client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "sofa", Text: "green"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000000), res.Price)
+	assert.Equal(t, "home/furniture", res.Category)
+
+	res, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Price: 1500000, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price", "location"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500000), res.Price)
+	assert.Nil(t, res.Location)
+	assert.Equal(t, "green", res.Text)
+
+	_, err = client.TransitionAd(ctx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "pending"})
+	require.NoError(t, err)
+
+	list, err := client.ListAds(ctx, &grpcPort.ListAdsRequest{AuthorId: proto.Int64(user.Id), Status: "all", Category: "home", MaxPrice: proto.Int64(1500000)})
+	assert.NoError(t, err)
+	require.Len(t, list.List, 1)
+	assert.Equal(t, ad.Id, list.List[0].Id)
+
+	list, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{AuthorId: proto.Int64(user.Id), Status: "all", MinPrice: proto.Int64(1500001)})
+	assert.NoError(t, err)
+	assert.Empty(t, list.List)
+
+	_, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{Category: "furniture"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// Тег validate поля Currency нельзя собрать из ads.Currencies, поэтому их совпадение проверяется отдельно
+func TestCurrenciesMatchValidateTag(t *testing.T) {
+	field, ok := reflect.TypeOf(ads.Details{}).FieldByName("Currency")
+	require.True(t, ok)
+	assert.Equal(t, "in:"+strings.Join(ads.Currencies, ","), field.Tag.Get("validate"))
+}
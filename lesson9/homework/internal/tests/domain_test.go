@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,35 +11,103 @@ import (
 func TestChangeStatusAdOfAnotherUser(t *testing.T) {
 	client := getTestClient()
 
-	resp, err := client.createAd(123, "hello", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 
-	_, err = client.changeAdStatus(100, resp.Data.ID, true)
+	_, err = client.transitionAd(1, resp.Data.ID, "pending", "")
 	assert.ErrorIs(t, err, ErrForbidden)
 }
 
 func TestUpdateAdOfAnotherUser(t *testing.T) {
 	client := getTestClient()
 
-	resp, err := client.createAd(123, "hello", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(100, resp.Data.ID, "title", "text")
+	_, err = client.updateAd(1, resp.Data.ID, "title", "text")
 	assert.ErrorIs(t, err, ErrForbidden)
 }
 
 func TestCreateAd_ID(t *testing.T) {
 	client := getTestClient()
 
-	resp, err := client.createAd(123, "hello", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 	assert.Equal(t, resp.Data.ID, int64(0))
 
-	resp, err = client.createAd(123, "hello", "world")
+	resp, err = client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 	assert.Equal(t, resp.Data.ID, int64(1))
 
-	resp, err = client.createAd(123, "hello", "world")
+	resp, err = client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 	assert.Equal(t, resp.Data.ID, int64(2))
 }
+
+func TestUpdateAd_Concurrent(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	titles := map[string]bool{}
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		title := fmt.Sprintf("title %d", i)
+		titles[title] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := client.updateAd(0, resp.Data.ID, title, "text")
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	gotAd, err := client.getAdByIDAs(0, resp.Data.ID)
+	assert.NoError(t, err)
+	assert.True(t, titles[gotAd.Data.Title])
+	assert.Equal(t, gotAd.Data.Text, "text")
+}
+
+func TestCreateAd_ConcurrentIDsAreUnique(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	mx := &sync.Mutex{}
+	ids := map[int64]bool{}
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.createAd(0, "hello", "world")
+			assert.NoError(t, err)
+
+			mx.Lock()
+			defer mx.Unlock()
+			ids[resp.Data.ID] = true
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, ids, 20)
+}
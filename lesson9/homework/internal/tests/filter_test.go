@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework9/internal/app"
+	"homework9/internal/users"
+)
+
+func TestFilterAdsByStatus(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	createPublishedAds(t, client, "published")
+
+	draft, err := client.createAd(0, "draft", "text")
+	assert.NoError(t, err)
+
+	_, err = client.createAd(1, "other draft", "text")
+	assert.NoError(t, err)
+
+	ads, err := client.filterAdsAs(0, app.WithAuthorID(0), app.WithStatus(app.StatusUnpublished))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+	assert.Equal(t, ads.Data[0].ID, draft.Data.ID)
+	assert.False(t, ads.Data[0].Published)
+
+	ads, err = client.filterAdsAs(0, app.WithAuthorID(0), app.WithStatus(app.StatusAll))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+
+	require.NoError(t, client.repo.UpdateUserRole(context.Background(), 1, users.RoleModerator))
+
+	ads, err = client.filterAdsAs(1, app.WithStatus(app.StatusAll))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 3)
+
+	_, err = client.filterAdsQuery(url.Values{"status": {"deleted"}})
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestFilterAdsByTitle(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "Red Sedan", "blue sedan", "bicycle")
+
+	ads, err := client.filterAds(app.WithTitleContains("SEDAN"))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+
+	ads, err = client.filterAds(app.WithTitleContains("sedan"), app.WithSort(app.SortByTitle, false))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+	assert.Equal(t, ads.Data[0].ID, created[0].ID)
+
+	empty, err := client.filterAds(app.WithTitleContains("truck"))
+	assert.NoError(t, err)
+	assert.Empty(t, empty.Data)
+}
+
+func TestFilterAdsByUpdateDate(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	created := createPublishedAds(t, client, "first", "second", "third")
+
+	timePoint := time.Now().UTC()
+
+	_, err := client.updateAd(0, created[1].ID, "second", "updated")
+	assert.NoError(t, err)
+
+	ads, err := client.filterAds(app.WithUpdatedAfter(timePoint))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+	assert.Equal(t, ads.Data[0].ID, created[1].ID)
+
+	ads, err = client.filterAds(app.WithUpdatedBefore(timePoint))
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 2)
+
+	ads, err = client.filterAds(app.WithUpdatedAfter(timePoint), app.WithTitleContains("first"))
+	assert.NoError(t, err)
+	assert.Empty(t, ads.Data)
+}
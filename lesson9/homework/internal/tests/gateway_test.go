@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/app"
+	"homework9/internal/ports/gateway"
+	"homework9/internal/users"
+)
+
+type gatewayResponse struct {
+	Data       json.RawMessage `json:"data"`
+	NextCursor *string         `json:"next_cursor"`
+	Error      *string         `json:"error"`
+}
+
+// getGatewayServer поднимает REST-шлюз без маршрутов httpgin поверх gRPC-клиента на bufconn
+func getGatewayServer(t *testing.T) *httptest.Server {
+	return getGatewayServerWithRepo(t, adrepo.New())
+}
+
+func getGatewayServerWithRepo(t *testing.T, repo app.Repository) *httptest.Server {
+	client, _ := getGRPCClientWithApp(t, newTestApp(repo))
+
+	handler, err := gateway.NewHandler(context.Background(), client)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// gatewayDo выполняет запрос с токеном доступа token, пустой токен - анонимный запрос
+func gatewayDo(t *testing.T, srv *httptest.Server, method, path, token string, body any) (int, gatewayResponse) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var out gatewayResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+
+	return resp.StatusCode, out
+}
+
+// gatewayUser регистрирует пользователя и возвращает его токен доступа
+func gatewayUser(t *testing.T, srv *httptest.Server, name string) string {
+	_, token := gatewayUserID(t, srv, name)
+	return token
+}
+
+// gatewayUserID регистрирует пользователя и возвращает его ID и токен доступа
+func gatewayUserID(t *testing.T, srv *httptest.Server, name string) (int64, string) {
+	code, resp := gatewayDo(t, srv, http.MethodPost, "/api/v1/users", "", map[string]any{"name": name, "email": strings.ToLower(name) + "@box.com", "password": testPassword})
+	require.Equal(t, http.StatusOK, code)
+
+	var user map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &user))
+
+	// int64 в JSON передается строкой
+	userID, err := strconv.ParseInt(user["id"].(string), 10, 64)
+	require.NoError(t, err)
+
+	code, resp = gatewayDo(t, srv, http.MethodPost, "/api/v1/sessions", "", map[string]any{"user_id": user["id"], "password": testPassword})
+	require.Equal(t, http.StatusOK, code)
+
+	var session map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &session))
+
+	return userID, session["token"].(string)
+}
+
+// gatewayModerator регистрирует модератора и возвращает его токен доступа
+func gatewayModerator(t *testing.T, srv *httptest.Server, repo app.Repository) string {
+	modID, token := gatewayUserID(t, srv, "Mod")
+	require.NoError(t, repo.UpdateUserRole(context.Background(), modID, users.RoleModerator))
+
+	return token
+}
+
+func TestGatewayCreateAndGetAd(t *testing.T) {
+	repo := adrepo.New()
+	srv := getGatewayServerWithRepo(t, repo)
+
+	code, resp := gatewayDo(t, srv, http.MethodPost, "/api/v1/users", "", map[string]any{"name": "Oleg", "email": "boss@gmail.com", "password": testPassword})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, resp.Error)
+
+	var user map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &user))
+	assert.Equal(t, "Oleg", user["name"])
+	assert.NotContains(t, user, "password")
+
+	code, resp = gatewayDo(t, srv, http.MethodPost, "/api/v1/sessions", "", map[string]any{"user_id": user["id"], "password": testPassword})
+	assert.Equal(t, http.StatusOK, code)
+
+	var session map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &session))
+	token := session["token"].(string)
+
+	code, resp = gatewayDo(t, srv, http.MethodPost, "/api/v1/ads", token, map[string]any{"title": "hello", "text": "world"})
+	assert.Equal(t, http.StatusOK, code)
+
+	var ad map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &ad))
+	assert.Equal(t, "hello", ad["title"])
+	assert.Equal(t, false, ad["published"])
+
+	assert.Equal(t, "draft", ad["state"])
+
+	code, resp = gatewayDo(t, srv, http.MethodPut, "/api/v1/ads/0/state", token, map[string]any{"state": "pending"})
+	assert.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal(resp.Data, &ad))
+	assert.Equal(t, "pending", ad["state"])
+
+	mod := gatewayModerator(t, srv, repo)
+
+	code, resp = gatewayDo(t, srv, http.MethodPut, "/api/v1/ads/0/state", mod, map[string]any{"state": "published"})
+	assert.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal(resp.Data, &ad))
+	assert.Equal(t, true, ad["published"])
+
+	code, resp = gatewayDo(t, srv, http.MethodGet, "/api/v1/ads/0/transitions", token, nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	// списки отдаются массивом в data, как и список объявлений
+	var history []map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &history))
+	assert.Len(t, history, 2)
+	assert.Nil(t, resp.NextCursor)
+
+	code, resp = gatewayDo(t, srv, http.MethodGet, "/api/v1/ads/0", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal(resp.Data, &ad))
+	assert.Equal(t, "world", ad["text"])
+}
+
+func TestGatewayErrors(t *testing.T) {
+	srv := getGatewayServer(t)
+
+	code, resp := gatewayDo(t, srv, http.MethodGet, "/api/v1/ads/100", "", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "null", string(resp.Data))
+	require.NotNil(t, resp.Error)
+
+	oleg := gatewayUser(t, srv, "Oleg")
+
+	code, resp = gatewayDo(t, srv, http.MethodPost, "/api/v1/ads", oleg, map[string]any{"title": "", "text": "world"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	require.NotNil(t, resp.Error)
+
+	code, resp = gatewayDo(t, srv, http.MethodPost, "/api/v1/ads", "", map[string]any{"title": "hello", "text": "world"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	require.NotNil(t, resp.Error)
+
+	bob := gatewayUser(t, srv, "Bob")
+	_, _ = gatewayDo(t, srv, http.MethodPost, "/api/v1/ads", oleg, map[string]any{"title": "hello", "text": "world"})
+
+	code, resp = gatewayDo(t, srv, http.MethodPut, "/api/v1/ads/0", bob, map[string]any{"title": "hi", "text": "there"})
+	assert.Equal(t, http.StatusForbidden, code)
+	require.NotNil(t, resp.Error)
+}
+
+func TestGatewayListAndSearchAds(t *testing.T) {
+	repo := adrepo.New()
+	srv := getGatewayServerWithRepo(t, repo)
+
+	oleg := gatewayUser(t, srv, "Oleg")
+	mod := gatewayModerator(t, srv, repo)
+
+	for _, title := range []string{"banana", "apple", "cherry"} {
+		code, resp := gatewayDo(t, srv, http.MethodPost, "/api/v1/ads", oleg, map[string]any{"title": title, "text": "fruit"})
+		require.Equal(t, http.StatusOK, code)
+
+		var ad map[string]any
+		require.NoError(t, json.Unmarshal(resp.Data, &ad))
+
+		code, _ = gatewayDo(t, srv, http.MethodPut, "/api/v1/ads/"+ad["id"].(string)+"/state", oleg, map[string]any{"state": "pending"})
+		require.Equal(t, http.StatusOK, code)
+
+		code, _ = gatewayDo(t, srv, http.MethodPut, "/api/v1/ads/"+ad["id"].(string)+"/state", mod, map[string]any{"state": "published"})
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	code, resp := gatewayDo(t, srv, http.MethodGet, "/api/v1/ads?sort=title&limit=2", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	require.NotNil(t, resp.NextCursor)
+	assert.NotEmpty(t, *resp.NextCursor)
+
+	var list []map[string]any
+	require.NoError(t, json.Unmarshal(resp.Data, &list))
+	require.Len(t, list, 2)
+	assert.Equal(t, "apple", list[0]["title"])
+	assert.Equal(t, "banana", list[1]["title"])
+
+	// /ads/search не должен попадать в GetAd
+	code, resp = gatewayDo(t, srv, http.MethodGet, "/api/v1/ads/search?query=cher", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal(resp.Data, &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "cherry", list[0]["title"])
+
+	code, resp = gatewayDo(t, srv, http.MethodGet, "/api/v1/ads?title=nothing", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	require.NoError(t, json.Unmarshal(resp.Data, &list))
+	assert.Empty(t, list)
+}
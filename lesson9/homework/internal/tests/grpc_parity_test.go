@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"homework9/internal/adapters/adrepo"
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+func TestGRPCAdTimestamps(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+	assert.NotNil(t, ad.CreationDate)
+	assert.WithinDuration(t, time.Now(), ad.CreationDate.AsTime(), time.Minute)
+	assert.Nil(t, ad.UpdateDate)
+	assert.Nil(t, ad.DeletedAt)
+
+	updated, err := client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "привет", Text: "мир"})
+	assert.NoError(t, err)
+	assert.NotNil(t, updated.UpdateDate)
+	assert.Equal(t, ad.CreationDate.AsTime(), updated.CreationDate.AsTime())
+}
+
+func TestGRPCGetAd(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	res, err := client.GetAd(bobCtx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, ad.Id, res.Id)
+	assert.Equal(t, "hello", res.Title)
+
+	_, err = client.GetAd(ctx, &grpcPort.GetAdRequest{AdId: 100})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCUpdateUser(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "boss@gmail.com", Password: testPassword})
+	assert.NoError(t, err)
+	userCtx := loginGRPC(t, ctx, client, user.Id)
+	assert.Equal(t, "boss@gmail.com", user.Email)
+
+	res, err := client.UpdateUser(userCtx, &grpcPort.UpdateUserRequest{Id: user.Id, Name: "Vladimir", Email: "newboss@gmail.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Vladimir", res.Name)
+	assert.Equal(t, "newboss@gmail.com", res.Email)
+
+	got, err := client.GetUser(ctx, &grpcPort.GetUserRequest{Id: user.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, "Vladimir", got.Name)
+	assert.Equal(t, "newboss@gmail.com", got.Email)
+
+	_, err = client.UpdateUser(userCtx, &grpcPort.UpdateUserRequest{Id: 100, Name: "Vladimir", Email: "newboss@gmail.com"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.UpdateUser(ctx, &grpcPort.UpdateUserRequest{Id: user.Id, Name: "Vladimir", Email: "newboss@gmail.com"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGRPCSearchAds(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	for _, title := range []string{"golang course", "python course", "golang book"} {
+		ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: title, Text: "text"})
+		assert.NoError(t, err)
+
+		publishGRPC(t, client, bobCtx, modCtx, ad.Id)
+	}
+
+	res, err := client.SearchAds(ctx, &grpcPort.SearchAdsRequest{Query: "golang"})
+	assert.NoError(t, err)
+	assert.Len(t, res.List, 2)
+
+	res, err = client.SearchAds(ctx, &grpcPort.SearchAdsRequest{Query: "golang course", Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, res.List, 1)
+	assert.Equal(t, "golang course", res.List[0].Title)
+
+	res, err = client.SearchAds(ctx, &grpcPort.SearchAdsRequest{Query: "rust"})
+	assert.NoError(t, err)
+	assert.Empty(t, res.List)
+
+	_, err = client.SearchAds(ctx, &grpcPort.SearchAdsRequest{Query: "  "})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCSearchAdByName(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	published, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+	publishGRPC(t, client, bobCtx, modCtx, published.Id)
+
+	_, err = client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "draft", Text: "world"})
+	assert.NoError(t, err)
+
+	res, err := client.SearchAdByName(ctx, &grpcPort.SearchAdByNameRequest{Title: "hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, published.Id, res.Id)
+
+	// неопубликованное объявление поиском по названию не находится
+	_, err = client.SearchAdByName(ctx, &grpcPort.SearchAdByNameRequest{Title: "draft"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCListAdsFilter(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	dob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	dobCtx := loginGRPC(t, ctx, client, dob.Id)
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	for _, title := range []string{"banana", "apple", "cherry"} {
+		ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: title, Text: "text"})
+		assert.NoError(t, err)
+
+		publishGRPC(t, client, bobCtx, modCtx, ad.Id)
+	}
+
+	draft, err := client.CreateAd(dobCtx, &grpcPort.CreateAdRequest{Title: "draft", Text: "text"})
+	assert.NoError(t, err)
+
+	page, err := client.ListAds(ctx, &grpcPort.ListAdsRequest{Sort: "title", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.List, 2)
+	assert.Equal(t, "apple", page.List[0].Title)
+	assert.Equal(t, "banana", page.List[1].Title)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{Sort: "title", Limit: 2, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, page.List, 1)
+	assert.Equal(t, "cherry", page.List[0].Title)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{Sort: "title", Descending: true, Title: "an"})
+	assert.NoError(t, err)
+	assert.Len(t, page.List, 1)
+	assert.Equal(t, "banana", page.List[0].Title)
+
+	page, err = client.ListAds(dobCtx, &grpcPort.ListAdsRequest{AuthorId: &dob.Id, Status: "all"})
+	assert.NoError(t, err)
+	assert.Len(t, page.List, 1)
+	assert.Equal(t, draft.Id, page.List[0].Id)
+
+	// чужие неопубликованные объявления видят только модераторы
+	_, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{AuthorId: &dob.Id, Status: "all"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.ListAds(bobCtx, &grpcPort.ListAdsRequest{AuthorId: &dob.Id, Status: "draft"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	page, err = client.ListAds(modCtx, &grpcPort.ListAdsRequest{Status: "unpublished"})
+	assert.NoError(t, err)
+	assert.Len(t, page.List, 1)
+
+	page, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{PublishedAfter: timestamppb.New(time.Now().Add(time.Hour))})
+	assert.NoError(t, err)
+	assert.Empty(t, page.List)
+
+	_, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{Status: "sold"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{Sort: "price"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{Cursor: "broken"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCRestoreAd(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteAd(bobCtx, &grpcPort.DeleteAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+
+	_, err = client.GetAd(ctx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err := client.RestoreAd(bobCtx, &grpcPort.RestoreAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	assert.Nil(t, res.DeletedAt)
+
+	_, err = client.RestoreAd(bobCtx, &grpcPort.RestoreAdRequest{AdId: ad.Id})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -3,15 +3,22 @@ package tests
 import (
 	"context"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 	"homework9/internal/adapters/adrepo"
 	"homework9/internal/app"
 	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/ports/grpc/interceptors"
+	"homework9/internal/users"
 )
 
 func TestGRRPCCreateUser(t *testing.T) {
@@ -25,7 +32,7 @@ func TestGRRPCCreateUser(t *testing.T) {
 		srv.Stop()
 	})
 
-	svc := grpcPort.NewService(app.NewApp(adrepo.New()))
+	svc := grpcPort.NewService(newTestApp(adrepo.New()))
 	grpcPort.RegisterAdServiceServer(srv, svc)
 
 	go func() {
@@ -49,8 +56,273 @@ func TestGRRPCCreateUser(t *testing.T) {
 	})
 
 	client := grpcPort.NewAdServiceClient(conn)
-	res, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg"})
+	res, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
 	assert.NoError(t, err, "client.GetUser")
 
 	assert.Equal(t, "Oleg", res.Name)
 }
+
+// getGRPCClient поднимает AdService на bufconn поверх нового in-memory репозитория
+func getGRPCClient(t *testing.T) (grpcPort.AdServiceClient, context.Context) {
+	return getGRPCClientWithApp(t, newTestApp(adrepo.New()))
+}
+
+// getGRPCClientWithApp поднимает AdService поверх a. Без опций сервер только аутентифицирует вызовы
+func getGRPCClientWithApp(t *testing.T, a app.App, opts ...grpc.ServerOption) (grpcPort.AdServiceClient, context.Context) {
+	if len(opts) == 0 {
+		opts = []grpc.ServerOption{
+			grpc.UnaryInterceptor(interceptors.AuthUnary(a)),
+			grpc.StreamInterceptor(interceptors.AuthStream(a)),
+		}
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	t.Cleanup(func() {
+		lis.Close()
+	})
+
+	srv := grpc.NewServer(opts...)
+	t.Cleanup(func() {
+		srv.Stop()
+	})
+
+	svc := grpcPort.NewService(a)
+	grpcPort.RegisterAdServiceServer(srv, svc)
+
+	go func() {
+		assert.NoError(t, srv.Serve(lis), "srv.Serve")
+	}()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(func() {
+		cancel()
+	})
+
+	conn, err := grpc.DialContext(ctx, "", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err, "grpc.DialContext")
+
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return grpcPort.NewAdServiceClient(conn), ctx
+}
+
+// loginGRPC возвращает контекст, вызовы с которым выполняются от имени пользователя userID
+func loginGRPC(t *testing.T, ctx context.Context, client grpcPort.AdServiceClient, userID int64) context.Context {
+	res, err := client.Login(ctx, &grpcPort.LoginRequest{UserId: userID, Password: testPassword})
+	require.NoError(t, err)
+
+	return interceptors.WithBearerToken(ctx, res.Token)
+}
+
+// moderatorGRPC регистрирует модератора и возвращает контекст его вызовов.
+// Роль назначается в обход API, как в getTestClientWithRoles
+func moderatorGRPC(t *testing.T, ctx context.Context, client grpcPort.AdServiceClient, repo app.Repository) context.Context {
+	mod, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Mod", Email: "mod@box.com", Password: testPassword})
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateUserRole(context.Background(), mod.Id, users.RoleModerator))
+
+	return loginGRPC(t, ctx, client, mod.Id)
+}
+
+// publishGRPC отправляет объявление на проверку от имени автора и одобряет его модератором
+func publishGRPC(t *testing.T, client grpcPort.AdServiceClient, authorCtx context.Context, modCtx context.Context, adID int64) *grpcPort.AdResponse {
+	_, err := client.TransitionAd(authorCtx, &grpcPort.TransitionAdRequest{AdId: adID, State: "pending"})
+	require.NoError(t, err)
+
+	res, err := client.TransitionAd(modCtx, &grpcPort.TransitionAdRequest{AdId: adID, State: "published"})
+	require.NoError(t, err)
+
+	return res
+}
+
+func TestGRPCGetUser(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	assert.NoError(t, err)
+
+	res, err := client.GetUser(ctx, &grpcPort.GetUserRequest{Id: user.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, res.Id)
+	assert.Equal(t, "Oleg", res.Name)
+
+	_, err = client.GetUser(ctx, &grpcPort.GetUserRequest{Id: 100})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCCreateAd(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	userCtx := loginGRPC(t, ctx, client, user.Id)
+
+	res, err := client.CreateAd(userCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+	assert.Zero(t, res.Id)
+	assert.Equal(t, "hello", res.Title)
+	assert.Equal(t, "world", res.Text)
+	assert.Equal(t, user.Id, res.AuthorId)
+	assert.False(t, res.Published)
+
+	_, err = client.CreateAd(userCtx, &grpcPort.CreateAdRequest{Title: "", Text: "world"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.CreateAd(ctx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.CreateAd(interceptors.WithBearerToken(ctx, "bad"), &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGRPCTransitionAd(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	dob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	dobCtx := loginGRPC(t, ctx, client, dob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	_, err = client.TransitionAd(dobCtx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "pending"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.TransitionAd(bobCtx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "published"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	res := publishGRPC(t, client, bobCtx, modCtx, ad.Id)
+	assert.True(t, res.Published)
+	assert.Equal(t, "published", res.State)
+
+	_, err = client.TransitionAd(modCtx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "rejected"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	res, err = client.TransitionAd(modCtx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "rejected", Reason: "spam"})
+	assert.NoError(t, err)
+	assert.False(t, res.Published)
+	assert.Equal(t, "spam", res.RejectionReason)
+
+	history, err := client.ListAdTransitions(bobCtx, &grpcPort.ListAdTransitionsRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	require.Len(t, history.List, 3)
+	assert.Equal(t, "published", history.List[2].From)
+	assert.Equal(t, "rejected", history.List[2].To)
+	assert.Equal(t, "spam", history.List[2].Reason)
+
+	_, err = client.ListAdTransitions(dobCtx, &grpcPort.ListAdTransitionsRequest{AdId: ad.Id})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestGRPCUpdateAd(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	res, err := client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "привет", Text: "мир"})
+	assert.NoError(t, err)
+	assert.Equal(t, "привет", res.Title)
+	assert.Equal(t, "мир", res.Text)
+
+	_, err = client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: strings.Repeat("a", 101), Text: "мир"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: 100, Title: "привет", Text: "мир"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCListAds(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	list, err := client.ListAds(ctx, &grpcPort.ListAdsRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, list.List)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	publishGRPC(t, client, bobCtx, moderatorGRPC(t, ctx, client, repo), ad.Id)
+
+	_, err = client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "best cat", Text: "not for sale"})
+	assert.NoError(t, err)
+
+	list, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, list.List, 1)
+	assert.Equal(t, ad.Id, list.List[0].Id)
+	assert.True(t, list.List[0].Published)
+}
+
+func TestGRPCDeleteAd(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	dob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	dobCtx := loginGRPC(t, ctx, client, dob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteAd(dobCtx, &grpcPort.DeleteAdRequest{AdId: ad.Id})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	deleted, err := client.DeleteAd(bobCtx, &grpcPort.DeleteAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, ad.Id, deleted.Id)
+	assert.Equal(t, "hello", deleted.Title)
+
+	_, err = client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "привет", Text: "мир"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCDeleteUser(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	deleted, err := client.DeleteUser(bobCtx, &grpcPort.DeleteUserRequest{Id: bob.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", deleted.Name)
+
+	_, err = client.GetUser(ctx, &grpcPort.GetUserRequest{Id: bob.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetAd(ctx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// токен удаленного пользователя больше не действует
+	_, err = client.DeleteUser(bobCtx, &grpcPort.DeleteUserRequest{Id: bob.Id})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+func TestGRPCImportAds(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	stream, err := client.ImportAds(bobCtx)
+	require.NoError(t, err)
+
+	requests := []*grpcPort.CreateAdRequest{
+		{Title: "hello", Text: "world"},
+		{Title: "", Text: "world"},
+		{Title: "hello", Text: strings.Repeat("a", 501)},
+		{Title: strings.Repeat("a", 101), Text: "not for sale"},
+		{Title: "best cat", Text: "not for sale"},
+	}
+	for _, req := range requests {
+		require.NoError(t, stream.Send(req))
+	}
+
+	summary, err := stream.CloseAndRecv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), summary.Total)
+	assert.Equal(t, int64(2), summary.Imported)
+	assert.Equal(t, int64(3), summary.Failed)
+	assert.Len(t, summary.AdIds, 2)
+
+	assert.Len(t, summary.Errors, 3)
+	assert.Equal(t, int64(1), summary.Errors[0].Index)
+	assert.Equal(t, codes.InvalidArgument.String(), summary.Errors[0].Code)
+	assert.Equal(t, int64(2), summary.Errors[1].Index)
+	assert.Equal(t, codes.InvalidArgument.String(), summary.Errors[1].Code)
+	assert.Equal(t, int64(3), summary.Errors[2].Index)
+	assert.Equal(t, codes.InvalidArgument.String(), summary.Errors[2].Code)
+
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+	for _, adID := range summary.AdIds {
+		publishGRPC(t, client, bobCtx, modCtx, adID)
+	}
+
+	list, err := client.ListAds(ctx, &grpcPort.ListAdsRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, list.List, 2)
+	assert.Equal(t, "hello", list.List[0].Title)
+	assert.Equal(t, "best cat", list.List[1].Title)
+}
+
+func TestGRPCImportAdsEmptyStream(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, bob.Id)
+
+	stream, err := client.ImportAds(ctx)
+	require.NoError(t, err)
+
+	summary, err := stream.CloseAndRecv()
+	assert.NoError(t, err)
+	assert.Zero(t, summary.Total)
+	assert.Zero(t, summary.Imported)
+	assert.Zero(t, summary.Failed)
+	assert.Empty(t, summary.Errors)
+}
+
+func TestGRPCImportAdsUnauthenticated(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	stream, err := client.ImportAds(ctx)
+	require.NoError(t, err)
+
+	// анонимный стрим отклоняется целиком, а не поштучно в сводке
+	_ = stream.Send(&grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = repo.GetAdByID(context.Background(), 0)
+	assert.Error(t, err)
+}
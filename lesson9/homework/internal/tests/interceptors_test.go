@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/app"
+	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/ports/grpc/interceptors"
+	"homework9/internal/users"
+)
+
+// syncBuffer - буфер для логов, в который пишет сервер и читает тест
+type syncBuffer struct {
+	mx  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.String()
+}
+
+// panicApp паникует при создании пользователя
+type panicApp struct {
+	app.App
+}
+
+func (panicApp) CreateUser(context.Context, string, string, string) (*users.User, error) {
+	panic("boom")
+}
+
+func getGRPCClientWithInterceptors(t *testing.T, a app.App) (grpcPort.AdServiceClient, context.Context, *syncBuffer) {
+	logs := &syncBuffer{}
+	client, ctx := getGRPCClientWithApp(t, a, interceptors.ServerOptions(log.New(logs, "", 0), a)...)
+	return client, ctx, logs
+}
+
+func TestGRPCLoggingInterceptor(t *testing.T) {
+	client, ctx, logs := getGRPCClientWithInterceptors(t, newTestApp(adrepo.New()))
+
+	_, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	assert.NoError(t, err)
+
+	_, err = client.GetUser(ctx, &grpcPort.GetUserRequest{Id: 100})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	assert.Contains(t, logs.String(), "method=/ad.AdService/CreateUser code=OK")
+	assert.Contains(t, logs.String(), "method=/ad.AdService/GetUser code=NotFound")
+	assert.Contains(t, logs.String(), "peer=")
+	assert.Contains(t, logs.String(), "latency=")
+}
+
+func TestGRPCRecoveryInterceptor(t *testing.T) {
+	client, ctx, logs := getGRPCClientWithInterceptors(t, panicApp{App: newTestApp(adrepo.New())})
+
+	_, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "boom")
+
+	assert.Contains(t, logs.String(), "panic in /ad.AdService/CreateUser")
+	assert.Contains(t, logs.String(), "boom")
+	assert.Contains(t, logs.String(), "goroutine")
+	assert.Contains(t, logs.String(), "method=/ad.AdService/CreateUser code=Internal")
+
+	// сервер продолжает обслуживать запросы после паники
+	_, err = client.ListAds(ctx, &grpcPort.ListAdsRequest{})
+	assert.NoError(t, err)
+}
+
+func TestGRPCRequestIDInterceptor(t *testing.T) {
+	client, ctx, logs := getGRPCClientWithInterceptors(t, newTestApp(adrepo.New()))
+
+	var header metadata.MD
+	reqCtx := metadata.AppendToOutgoingContext(ctx, interceptors.RequestIDKey, "req-42")
+
+	_, err := client.CreateUser(reqCtx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"req-42"}, header.Get(interceptors.RequestIDKey))
+	assert.Contains(t, logs.String(), "request_id=req-42")
+
+	header = nil
+	_, err = client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob@box.com", Password: testPassword}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Len(t, header.Get(interceptors.RequestIDKey), 1)
+	assert.NotEmpty(t, header.Get(interceptors.RequestIDKey)[0])
+}
+
+func TestGRPCStreamInterceptors(t *testing.T) {
+	client, ctx, logs := getGRPCClientWithInterceptors(t, newTestApp(adrepo.New()))
+
+	watchCtx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx, interceptors.RequestIDKey, "watch-1"))
+	stream, err := client.WatchAds(watchCtx, &grpcPort.WatchAdsRequest{})
+	assert.NoError(t, err)
+
+	header, err := stream.Header()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"watch-1"}, header.Get(interceptors.RequestIDKey))
+
+	cancel()
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "method=/ad.AdService/WatchAds code=Canceled")
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, logs.String(), "request_id=watch-1")
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerationWorkflow(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	// черновик нельзя опубликовать, минуя проверку
+	_, err = client.transitionAd(0, ad.Data.ID, "published", "")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	response, err := client.transitionAd(0, ad.Data.ID, "pending", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", response.Data.State)
+
+	// автор не одобряет свои объявления
+	_, err = client.transitionAd(0, ad.Data.ID, "published", "")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// отклонение без причины
+	_, err = client.transitionAd(1, ad.Data.ID, "rejected", "")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	response, err = client.transitionAd(1, ad.Data.ID, "rejected", "no photos")
+	assert.NoError(t, err)
+	assert.Equal(t, "rejected", response.Data.State)
+	assert.Equal(t, "no photos", response.Data.RejectionReason)
+
+	response, err = client.transitionAd(0, ad.Data.ID, "pending", "")
+	assert.NoError(t, err)
+	assert.Empty(t, response.Data.RejectionReason)
+
+	response, err = client.transitionAd(1, ad.Data.ID, "published", "")
+	assert.NoError(t, err)
+	assert.True(t, response.Data.Published)
+
+	response, err = client.transitionAd(0, ad.Data.ID, "archived", "")
+	assert.NoError(t, err)
+	assert.False(t, response.Data.Published)
+
+	history, err := client.adTransitions(0, ad.Data.ID)
+	assert.NoError(t, err)
+	require.Len(t, history.Data, 5)
+
+	steps := []string{}
+	for _, transition := range history.Data {
+		steps = append(steps, transition.From+"->"+transition.To)
+	}
+	assert.Equal(t, []string{"draft->pending", "pending->rejected", "rejected->pending", "pending->published", "published->archived"}, steps)
+	assert.Equal(t, int64(1), history.Data[1].ActorID)
+	assert.Equal(t, "no photos", history.Data[1].Reason)
+	assert.False(t, history.Data[1].Date.IsZero())
+
+	// история видна модератору, но не постороннему
+	_, err = client.adTransitions(1, ad.Data.ID)
+	assert.NoError(t, err)
+
+	other, err := client.createUser("Dob", "dob@box.com")
+	require.NoError(t, err)
+
+	_, err = client.adTransitions(other.Data.ID, ad.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestTransitionAd_NotValid(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.transitionAd(0, ad.Data.ID, "deleted", "")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.transitionAd(0, ad.Data.ID, "pending", strings.Repeat("a", 501))
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.transitionAd(0, 100, "pending", "")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.transitionAd(2, ad.Data.ID, "pending", "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestFilterAdsByState(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	draft, err := client.createAd(0, "draft", "text")
+	require.NoError(t, err)
+
+	pending, err := client.createAd(0, "pending", "text")
+	require.NoError(t, err)
+
+	_, err = client.transitionAd(0, pending.Data.ID, "pending", "")
+	require.NoError(t, err)
+
+	published := createPublishedAds(t, client, "published")
+
+	response, err := client.filterAdsQueryAs(0, url.Values{"author_id": {"0"}, "status": {"pending"}})
+	assert.NoError(t, err)
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, pending.Data.ID, response.Data[0].ID)
+
+	response, err = client.filterAdsQueryAs(0, url.Values{"author_id": {"0"}, "status": {"draft"}})
+	assert.NoError(t, err)
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, draft.Data.ID, response.Data[0].ID)
+
+	response, err = client.filterAdsQueryAs(0, url.Values{"author_id": {"0"}, "status": {"unpublished"}})
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 2)
+
+	response, err = client.listAds()
+	assert.NoError(t, err)
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, published[0].ID, response.Data[0].ID)
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+func TestPatchAd(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	// текст не затирается, если передан только заголовок
+	response, err := client.patchAd(0, ad.Data.ID, `{"title": "hi"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", response.Data.Title)
+	assert.Equal(t, "world", response.Data.Text)
+
+	response, err = client.patchAd(0, ad.Data.ID, `{"text": "everyone"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", response.Data.Title)
+	assert.Equal(t, "everyone", response.Data.Text)
+
+	// null сбрасывает поле, а пустой заголовок не проходит валидацию
+	_, err = client.patchAd(0, ad.Data.ID, `{"title": null}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// пустой патч ничего не меняет, неизвестные поля игнорируются
+	response, err = client.patchAd(0, ad.Data.ID, `{"author_id": 5}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", response.Data.Title)
+	assert.Equal(t, int64(0), response.Data.AuthorID)
+}
+
+func TestPatchAd_NotValid(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+	_, _ = client.createUser("Dob", "dob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.patchAd(0, ad.Data.ID, `{"title": 5}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchAd(0, ad.Data.ID, `["title"]`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchAd(0, ad.Data.ID, `{"title": "`+strings.Repeat("a", 101)+`"}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchAd(1, ad.Data.ID, `{"title": "spam"}`)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.patchAd(0, 100, `{"title": "hi"}`)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	req, err := http.NewRequest(http.MethodPatch, client.baseURL+"/api/v1/ads/0", strings.NewReader(`{"title": "hi"}`))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "text/plain")
+	client.authorize(req, 0)
+
+	resp, err := client.client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestPatchAd_IfMatch(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	_, err = client.patchAd(0, ad.Data.ID, `{"title": "hi"}`)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPatch, client.baseURL+"/api/v1/ads/0", strings.NewReader(`{"text": "stale"}`))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/merge-patch+json")
+	req.Header.Add("If-Match", `"1"`)
+	client.authorize(req, 0)
+
+	var response adResponse
+	assert.ErrorIs(t, client.getResponse(req, &response), ErrPreconditionFailed)
+}
+
+func TestPatchUser(t *testing.T) {
+	client := getTestClient()
+
+	bob, err := client.createUser("Bob", "bob@box.com")
+	require.NoError(t, err)
+
+	_, err = client.createUser("Dob", "dob@box.com")
+	require.NoError(t, err)
+
+	// email не затирается, если передан только никнейм
+	user, err := client.patchUser(bob.Data.ID, bob.Data.ID, `{"name": "Bobby"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bobby", user.Data.Nickname)
+	assert.Equal(t, "bob@box.com", user.Data.Email)
+
+	user, err = client.patchUser(bob.Data.ID, bob.Data.ID, `{"email": "bobby@box.com"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bobby", user.Data.Nickname)
+	assert.Equal(t, "bobby@box.com", user.Data.Email)
+
+	_, err = client.patchUser(bob.Data.ID, bob.Data.ID, `{"email": null}`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchUser(bob.Data.ID, bob.Data.ID, `{"email": "dob@box.com"}`)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = client.patchUser(bob.Data.ID, 1, `{"name": "Bob"}`)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestGRPCUpdateAd_FieldMask(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, user.Id)
+
+	ad, err := client.CreateAd(ctx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+
+	res, err := client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "hi", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", res.Title)
+	assert.Equal(t, "world", res.Text)
+
+	_, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "hi", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"author_id"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// без маски меняются только заданные в запросе поля
+	res, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "hey", Text: "there"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hey", res.Title)
+	assert.Equal(t, "there", res.Text)
+
+	res, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Price: 1000, Location: &grpcPort.Location{Region: "Moscow", City: "Moscow"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hey", res.Title)
+	assert.Equal(t, "there", res.Text)
+	assert.Equal(t, int64(1000), res.Price)
+	assert.Equal(t, "Moscow", res.Location.GetCity())
+
+	// сбросить поле можно только явной маской
+	res, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), res.Price)
+	assert.Equal(t, "Moscow", res.Location.GetCity())
+}
+
+func TestGRPCUpdateUser_FieldMask(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, user.Id)
+
+	res, err := client.UpdateUser(ctx, &grpcPort.UpdateUserRequest{Id: user.Id, Name: "Olga", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Olga", res.Name)
+	assert.Equal(t, "oleg@box.com", res.Email)
+
+	_, err = client.UpdateUser(ctx, &grpcPort.UpdateUserRequest{Id: user.Id, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// без маски меняются только заданные в запросе поля, как и у объявлений
+	res, err = client.UpdateUser(ctx, &grpcPort.UpdateUserRequest{Id: user.Id, Email: "olga@box.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Olga", res.Name)
+	assert.Equal(t, "olga@box.com", res.Email)
+}
@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/app"
+	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/users"
+)
+
+func TestPolicy(t *testing.T) {
+	author := &users.User{ID: 1, Role: users.RoleUser}
+	stranger := &users.User{ID: 2, Role: users.RoleUser}
+	moderator := &users.User{ID: 3, Role: users.RoleModerator}
+	admin := &users.User{ID: 4, Role: users.RoleAdmin}
+
+	tests := []struct {
+		action app.Action
+		actor  *users.User
+		allow  bool
+	}{
+		{app.ActionUpdateAd, author, true},
+		{app.ActionUpdateAd, stranger, false},
+		{app.ActionUpdateAd, moderator, false},
+		{app.ActionUpdateAd, admin, false},
+		{app.ActionSubmitAd, author, true},
+		{app.ActionSubmitAd, moderator, false},
+		{app.ActionApproveAd, author, false},
+		{app.ActionApproveAd, moderator, true},
+		{app.ActionApproveAd, admin, true},
+		{app.ActionRejectAd, stranger, false},
+		{app.ActionRejectAd, moderator, true},
+		{app.ActionArchiveAd, author, true},
+		{app.ActionArchiveAd, stranger, false},
+		{app.ActionArchiveAd, moderator, true},
+		{app.ActionViewAdTransitions, author, true},
+		{app.ActionViewAdTransitions, stranger, false},
+		{app.ActionViewAdTransitions, moderator, true},
+		{app.ActionDeleteAd, stranger, false},
+		{app.ActionDeleteAd, moderator, true},
+		{app.ActionDeleteAd, admin, true},
+		{app.ActionRestoreAd, stranger, false},
+		{app.ActionRestoreAd, moderator, true},
+		{app.ActionUpdateUser, author, true},
+		{app.ActionUpdateUser, moderator, false},
+		{app.ActionUpdateUser, admin, true},
+		{app.ActionDeleteUser, stranger, false},
+		{app.ActionDeleteUser, admin, true},
+		{app.ActionSetUserRole, author, false},
+		{app.ActionSetUserRole, moderator, false},
+		{app.ActionSetUserRole, admin, true},
+		{app.Action("unknown"), admin, false},
+	}
+
+	for _, tt := range tests {
+		// владелец ресурса во всех случаях - author
+		assert.Equal(t, tt.allow, app.Can(tt.actor, tt.action, author.ID), "%s by %s", tt.action, tt.actor.Role)
+	}
+
+	// действия без владельца решает только роль
+	assert.True(t, app.Allowed(users.RoleAdmin, app.ActionViewAuditLog, false))
+	assert.False(t, app.Allowed(users.RoleModerator, app.ActionViewAuditLog, false))
+	assert.False(t, app.Allowed(users.RoleUser, app.ActionViewAuditLog, true))
+	assert.False(t, app.Allowed(users.RoleUser, app.ActionUpdateAd, false))
+}
+
+// getTestClientWithRoles создает пользователей Bob (0, user), Mod (1, moderator) и Root (2, admin)
+func getTestClientWithRoles(t *testing.T) *testClient {
+	repo := adrepo.New()
+	client := getTestClientWithRepo(repo)
+
+	for _, name := range []string{"Bob", "Mod", "Root"} {
+		_, err := client.createUser(name, name+"@box.com")
+		require.NoError(t, err)
+	}
+
+	// первого администратора назначает оператор в обход API, как -admin-id в cmd/main
+	require.NoError(t, repo.UpdateUserRole(context.Background(), 2, users.RoleAdmin))
+
+	_, err := client.setUserRole(2, 1, string(users.RoleModerator))
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestModeratorAds(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	ads := createPublishedAds(t, client, "hello")
+
+	_, err := client.updateAd(1, ads[0].ID, "spam", "spam")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	response, err := client.transitionAd(1, ads[0].ID, "rejected", "spam")
+	assert.NoError(t, err)
+	assert.False(t, response.Data.Published)
+
+	// отправить объявление на проверку повторно может только автор
+	_, err = client.transitionAd(1, ads[0].ID, "pending", "")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.deleteAd(1, ads[0].ID)
+	assert.NoError(t, err)
+
+	response, err = client.restoreAd(1, ads[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), response.Data.AuthorID)
+
+	// обычный пользователь не может удалить чужое объявление
+	modAd, err := client.createAd(1, "moderator", "ad")
+	require.NoError(t, err)
+
+	_, err = client.deleteAd(0, modAd.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestUnpublishedAdsAccess(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	_, err := client.createUser("Dob", "dob@box.com")
+	require.NoError(t, err)
+
+	draft, err := client.createAd(0, "draft", "text")
+	require.NoError(t, err)
+
+	attachment, err := client.uploadAttachment(0, draft.Data.ID, "cat.png", pngContent(10))
+	require.NoError(t, err)
+
+	// черновик видят автор и модераторы, для остальных его нет
+	for _, userID := range []int64{0, 1, 2} {
+		_, err = client.getAdByIDAs(userID, draft.Data.ID)
+		assert.NoError(t, err, userID)
+	}
+
+	for _, userID := range []int64{-1, 3} {
+		_, err = client.getAdByIDAs(userID, draft.Data.ID)
+		assert.ErrorIs(t, err, ErrNotFound, userID)
+
+		_, _, err = client.downloadAttachment(userID, draft.Data.ID, attachment.Data.ID)
+		assert.ErrorIs(t, err, ErrNotFound, userID)
+	}
+
+	for _, query := range []url.Values{
+		{"status": {"all"}},
+		{"status": {"draft"}},
+		{"status": {"unpublished"}, "author_id": {"0"}},
+	} {
+		_, err = client.filterAdsQuery(query)
+		assert.ErrorIs(t, err, ErrUnauthorized, query)
+
+		_, err = client.filterAdsQueryAs(3, query)
+		assert.ErrorIs(t, err, ErrForbidden, query)
+
+		response, err := client.filterAdsQueryAs(1, query)
+		assert.NoError(t, err, query)
+		assert.Len(t, response.Data, 1, query)
+	}
+
+	// свои объявления автор видит в любом состоянии, но только с фильтром по себе
+	response, err := client.filterAdsQueryAs(0, url.Values{"status": {"draft"}, "author_id": {"0"}})
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 1)
+
+	_, err = client.filterAdsQueryAs(0, url.Values{"status": {"draft"}})
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestAdminUsers(t *testing.T) {
+	client := getTestClientWithRoles(t)
+
+	_, err := client.updateUserAs(1, 0, "Vladimir", "newboss@gmail.com")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	response, err := client.updateUserAs(2, 0, "Vladimir", "newboss@gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "Vladimir", response.Data.Nickname)
+
+	_, err = client.setUserRole(1, 0, string(users.RoleModerator))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.setUserRole(0, 0, string(users.RoleAdmin))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.setUserRole(2, 0, "root")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.setUserRole(2, 100, string(users.RoleModerator))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.deleteUserAs(1, 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.deleteUserAs(2, 0)
+	assert.NoError(t, err)
+
+	_, err = client.getUserByID(0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUserRoleDefault(t *testing.T) {
+	client := getTestClient()
+
+	response, err := client.createUser("Bob", "bob@box.com")
+	assert.NoError(t, err)
+	assert.Equal(t, string(users.RoleUser), response.Data.Role)
+}
+
+func TestGRPCModerator(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	require.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	root, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Root", Email: "root@box.com", Password: testPassword})
+	require.NoError(t, err)
+	rootCtx := loginGRPC(t, ctx, client, root.Id)
+	require.NoError(t, repo.UpdateUserRole(context.Background(), root.Id, users.RoleAdmin))
+
+	mod, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Mod", Email: "mod@box.com", Password: testPassword})
+	require.NoError(t, err)
+	modCtx := loginGRPC(t, ctx, client, mod.Id)
+
+	_, err = client.SetUserRole(bobCtx, &grpcPort.SetUserRoleRequest{Id: mod.Id, Role: "moderator"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	res, err := client.SetUserRole(rootCtx, &grpcPort.SetUserRoleRequest{Id: mod.Id, Role: "moderator"})
+	assert.NoError(t, err)
+	assert.Equal(t, "moderator", res.Role)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+
+	_, err = client.UpdateAd(modCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "spam", Text: "spam"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.DeleteAd(modCtx, &grpcPort.DeleteAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"homework9/internal/readiness"
+)
+
+func TestReadinessFlag(t *testing.T) {
+	ready := &readiness.Flag{}
+	server := httptest.NewServer(ready.Handler())
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	ready.Set(true)
+
+	resp, err = http.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ready.Set(false)
+
+	resp, err = http.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+func TestCreateUser_InvalidEmail(t *testing.T) {
+	client := getTestClient()
+
+	for _, email := range []string{"", "bob", "bob@", "@box.com", "Bob <bob@box.com>"} {
+		_, err := client.createUser("Bob", email)
+		assert.ErrorIs(t, err, ErrBadRequest, email)
+	}
+}
+
+func TestCreateUser_NicknameLength(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("", "bob@box.com")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.createUser(strings.Repeat("a", 33), "bob@box.com")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.createUser(strings.Repeat("a", 32), "bob@box.com")
+	assert.NoError(t, err)
+}
+
+func TestCreateUser_DuplicateEmail(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("Bob", "bob@box.com")
+	assert.NoError(t, err)
+
+	// уникальность email не зависит от регистра
+	_, err = client.createUser("Dob", "BOB@box.com")
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestUpdateUser_Email(t *testing.T) {
+	client := getTestClient()
+
+	bob, err := client.createUser("Bob", "bob@box.com")
+	assert.NoError(t, err)
+
+	_, err = client.createUser("Dob", "dob@box.com")
+	assert.NoError(t, err)
+
+	_, err = client.updateUser(bob.Data.ID, "Bob", "Dob@Box.com")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = client.updateUser(bob.Data.ID, "Bob", "not an email")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// собственный email можно оставить, сменив регистр
+	user, err := client.updateUser(bob.Data.ID, "Bobby", "Bob@box.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob@box.com", user.Data.Email)
+}
+
+func TestGRPCCreateUser_AlreadyExists(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	_, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+
+	_, err = client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "Bob@Box.com", Password: testPassword})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob", Password: testPassword})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -2,23 +2,71 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 
 	"homework9/internal/adapters/adrepo"
+	"homework9/internal/adapters/blobmem"
+	"homework9/internal/ads"
 	"homework9/internal/app"
+	"homework9/internal/auth"
+	"homework9/internal/ports/gateway"
+	grpcPort "homework9/internal/ports/grpc"
+	"homework9/internal/ports/grpc/interceptors"
 	"homework9/internal/ports/httpgin"
 )
 
+// int64 в ответах REST API передаются строками, как в JSON-отображении proto
 type adData struct {
-	ID        int64  `json:"id"`
-	Title     string `json:"title"`
-	Text      string `json:"text"`
-	AuthorID  int64  `json:"author_id"`
-	Published bool   `json:"published"`
+	ID              int64     `json:"id,string"`
+	Title           string    `json:"title"`
+	Text            string    `json:"text"`
+	AuthorID        int64     `json:"author_id,string"`
+	Published       bool      `json:"published"`
+	State           string    `json:"state"`
+	RejectionReason string    `json:"rejection_reason"`
+	CreationDate    time.Time `json:"creation_date"`
+	UpdateDate      time.Time `json:"update_date"`
+	DeletedAt       time.Time `json:"deleted_at"`
+	Version         int64     `json:"version,string"`
+	Price           int64     `json:"price,string"`
+	Currency        string    `json:"currency"`
+	Category        string    `json:"category"`
+	// Location приходит только у объявлений с указанным местом
+	Location *locationData `json:"location"`
+	// Attachments приходят только в ответах с одним объявлением
+	Attachments []attachmentData `json:"attachments"`
+}
+
+type locationData struct {
+	Region string `json:"region"`
+	City   string `json:"city"`
+}
+
+type attachmentData struct {
+	ID           int64     `json:"id,string"`
+	Name         string    `json:"name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size,string"`
+	CreationDate time.Time `json:"creation_date"`
+}
+
+type attachmentResponse struct {
+	Data attachmentData `json:"data"`
 }
 
 type adResponse struct {
@@ -26,26 +74,153 @@ type adResponse struct {
 }
 
 type adsResponse struct {
-	Data []adData `json:"data"`
+	Data       []adData `json:"data"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+type transitionData struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	ActorID int64     `json:"actor_id,string"`
+	Reason  string    `json:"reason"`
+	Date    time.Time `json:"date"`
+}
+
+type transitionsResponse struct {
+	Data []transitionData `json:"data"`
+}
+
+type auditChangeData struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type auditData struct {
+	ID         int64             `json:"id,string"`
+	ActorID    int64             `json:"actor_id,string"`
+	Action     string            `json:"action"`
+	Resource   string            `json:"resource"`
+	ResourceID int64             `json:"resource_id,string"`
+	Changes    []auditChangeData `json:"changes"`
+	Date       time.Time         `json:"date"`
+}
+
+type auditResponse struct {
+	Data       []auditData `json:"data"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+type userData struct {
+	ID       int64  `json:"id,string"`
+	Nickname string `json:"name"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+}
+
+type userResponse struct {
+	Data userData `json:"data"`
+}
+
+type tokenData struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type tokenResponse struct {
+	Data tokenData `json:"data"`
 }
 
 var (
-	ErrBadRequest = fmt.Errorf("bad request")
-	ErrForbidden  = fmt.Errorf("forbidden")
+	ErrBadRequest   = fmt.Errorf("bad request")
+	ErrUnauthorized = fmt.Errorf("unauthorized")
+	ErrForbidden    = fmt.Errorf("forbidden")
+	ErrNotFound     = fmt.Errorf("not found")
+	ErrConflict     = fmt.Errorf("conflict")
+	// ErrPreconditionFailed - не совпал If-Match
+	ErrPreconditionFailed = fmt.Errorf("precondition failed")
+	ErrTooLarge           = fmt.Errorf("too large")
+	ErrUnsupportedMedia   = fmt.Errorf("unsupported media type")
 )
 
+// testPassword - пароль всех пользователей, созданных через testClient.createUser
+const testPassword = "password"
+
 type testClient struct {
 	client  *http.Client
 	baseURL string
+	// tokens - токены доступа пользователей, созданных этим клиентом, по их ID
+	tokens map[int64]string
+	repo   app.Repository
+}
+
+// newTestApp создает приложение с дешевым хешированием паролей, чтобы тесты не тратили время на bcrypt,
+// и хранилищем вложений в памяти
+func newTestApp(repo app.Repository, options ...app.Option) app.App {
+	defaults := []app.Option{app.WithPasswordCost(auth.MinPasswordCost), app.WithBlobStore(blobmem.New())}
+	return app.NewApp(repo, append(defaults, options...)...)
 }
 
 func getTestClient() *testClient {
-	server := httpgin.NewHTTPServer(":18080", app.NewApp(adrepo.New()))
+	return getTestClientWithRepo(adrepo.New())
+}
+
+func getTestClientWithRepo(repo app.Repository) *testClient {
+	return getTestClientWithApp(repo, newTestApp(repo))
+}
+
+// getTestClientWithApp поднимает HTTP-сервер поверх a, repo - репозиторий a
+func getTestClientWithApp(repo app.Repository, a app.App) *testClient {
+	server := httpgin.NewHTTPServer(":18080", a, newGatewayHandler(a))
 	testServer := httptest.NewServer(server.Handler)
 
 	return &testClient{
 		client:  testServer.Client(),
 		baseURL: testServer.URL,
+		tokens:  map[int64]string{},
+		repo:    repo,
+	}
+}
+
+// newGatewayHandler поднимает AdService поверх a на bufconn и возвращает его REST-шлюз, как в cmd/main
+func newGatewayHandler(a app.App) http.Handler {
+	lis := bufconn.Listen(1024 * 1024)
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(interceptors.AuthUnary(a)), grpc.StreamInterceptor(interceptors.AuthStream(a)))
+	grpcPort.RegisterAdServiceServer(srv, grpcPort.NewService(a))
+
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	conn, err := grpc.DialContext(context.Background(), "", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		panic(err)
+	}
+
+	handler, err := gateway.NewHandler(context.Background(), grpcPort.NewAdServiceClient(conn))
+	if err != nil {
+		panic(err)
+	}
+
+	return handler
+}
+
+// approveAd публикует объявление в обход модерации. Так тесты, которым модерация не важна,
+// не заводят модератора и не сдвигают ID остальных пользователей
+func approveAd(repo app.Repository, adID int64) error {
+	return repo.UpdateAdState(context.Background(), adID, ads.StatePublished, "")
+}
+
+// authorize выполняет запрос от имени пользователя userID. Если клиент не знает токена
+// этого пользователя, запрос уходит анонимным
+func (tc *testClient) authorize(req *http.Request, userID int64) {
+	if token, ok := tc.tokens[userID]; ok {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 }
 
@@ -56,12 +231,31 @@ func (tc *testClient) getResponse(req *http.Request, out any) error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
+
 		if resp.StatusCode == http.StatusBadRequest {
 			return ErrBadRequest
 		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			return ErrUnauthorized
+		}
 		if resp.StatusCode == http.StatusForbidden {
 			return ErrForbidden
 		}
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		if resp.StatusCode == http.StatusConflict {
+			return ErrConflict
+		}
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			return ErrPreconditionFailed
+		}
+		if resp.StatusCode == http.StatusRequestEntityTooLarge {
+			return ErrTooLarge
+		}
+		if resp.StatusCode == http.StatusUnsupportedMediaType {
+			return ErrUnsupportedMedia
+		}
 		return fmt.Errorf("unexpected status code: %s", resp.Status)
 	}
 
@@ -79,12 +273,11 @@ func (tc *testClient) getResponse(req *http.Request, out any) error {
 }
 
 func (tc *testClient) createAd(userID int64, title string, text string) (adResponse, error) {
-	body := map[string]any{
-		"user_id": userID,
-		"title":   title,
-		"text":    text,
-	}
+	return tc.createAdWithDetails(userID, map[string]any{"title": title, "text": text})
+}
 
+// createAdWithDetails создает объявление из произвольного тела запроса, например с ценой и категорией
+func (tc *testClient) createAdWithDetails(userID int64, body map[string]any) (adResponse, error) {
 	data, err := json.Marshal(body)
 	if err != nil {
 		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
@@ -96,6 +289,7 @@ func (tc *testClient) createAd(userID int64, title string, text string) (adRespo
 	}
 
 	req.Header.Add("Content-Type", "application/json")
+	tc.authorize(req, userID)
 
 	var response adResponse
 	err = tc.getResponse(req, &response)
@@ -106,10 +300,10 @@ func (tc *testClient) createAd(userID int64, title string, text string) (adRespo
 	return response, nil
 }
 
-func (tc *testClient) changeAdStatus(userID int64, adID int64, published bool) (adResponse, error) {
+func (tc *testClient) transitionAd(userID int64, adID int64, state string, reason string) (adResponse, error) {
 	body := map[string]any{
-		"user_id":   userID,
-		"published": published,
+		"state":  state,
+		"reason": reason,
 	}
 
 	data, err := json.Marshal(body)
@@ -117,12 +311,13 @@ func (tc *testClient) changeAdStatus(userID int64, adID int64, published bool) (
 		return adResponse{}, fmt.Errorf("unable to marshal: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/status", adID), bytes.NewReader(data))
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/state", adID), bytes.NewReader(data))
 	if err != nil {
 		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
 
 	req.Header.Add("Content-Type", "application/json")
+	tc.authorize(req, userID)
 
 	var response adResponse
 	err = tc.getResponse(req, &response)
@@ -133,11 +328,81 @@ func (tc *testClient) changeAdStatus(userID int64, adID int64, published bool) (
 	return response, nil
 }
 
+// publishAd отправляет объявление на проверку от имени автора userID и одобряет его через approveAd
+func (tc *testClient) publishAd(userID int64, adID int64) (adResponse, error) {
+	if _, err := tc.transitionAd(userID, adID, "pending", ""); err != nil {
+		return adResponse{}, err
+	}
+
+	if err := approveAd(tc.repo, adID); err != nil {
+		return adResponse{}, err
+	}
+
+	return tc.getAdByID(adID)
+}
+
+func (tc *testClient) adTransitions(userID int64, adID int64) (transitionsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/transitions", adID), nil)
+	if err != nil {
+		return transitionsResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response transitionsResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return transitionsResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) adHistory(userID int64, adID int64) (auditResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/history", adID), nil)
+	if err != nil {
+		return auditResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response auditResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return auditResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) auditLog(userID int64, query url.Values) (auditResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/audit?"+query.Encode(), nil)
+	if err != nil {
+		return auditResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response auditResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return auditResponse{}, err
+	}
+
+	return response, nil
+}
+
 func (tc *testClient) updateAd(userID int64, adID int64, title string, text string) (adResponse, error) {
+	return tc.updateAdIfMatch(userID, adID, title, text, "")
+}
+
+// updateAdIfMatch обновляет объявление с заголовком If-Match, пустой ifMatch - без заголовка
+func (tc *testClient) updateAdIfMatch(userID int64, adID int64, title string, text string, ifMatch string) (adResponse, error) {
+	// PUT заменяет заголовок и текст целиком, поэтому оба поля указаны в маске явно
 	body := map[string]any{
-		"user_id": userID,
-		"title":   title,
-		"text":    text,
+		"title":       title,
+		"text":        text,
+		"update_mask": "title,text",
 	}
 
 	data, err := json.Marshal(body)
@@ -151,6 +416,154 @@ func (tc *testClient) updateAd(userID int64, adID int64, title string, text stri
 	}
 
 	req.Header.Add("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Add("If-Match", ifMatch)
+	}
+	tc.authorize(req, userID)
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+// getAdETag возвращает заголовок ETag ответа на получение объявления
+func (tc *testClient) getAdETag(userID int64, adID int64) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unexpected error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %s", resp.Status)
+	}
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func (tc *testClient) getAdByID(adID int64) (adResponse, error) {
+	return tc.getAdByIDAs(-1, adID)
+}
+
+// getAdByIDAs запрашивает объявление от имени пользователя userID: неопубликованные видят только автор и модераторы
+func (tc *testClient) getAdByIDAs(userID int64, adID int64) (adResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), nil)
+
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+// uploadAttachment загружает content вложением объявления adID под именем name
+func (tc *testClient) uploadAttachment(userID int64, adID int64, name string, content []byte) (attachmentResponse, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		return attachmentResponse{}, fmt.Errorf("unable to create part: %w", err)
+	}
+
+	if _, err := part.Write(content); err != nil {
+		return attachmentResponse{}, fmt.Errorf("unable to write part: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return attachmentResponse{}, fmt.Errorf("unable to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/attachments", adID), body)
+	if err != nil {
+		return attachmentResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	tc.authorize(req, userID)
+
+	var response attachmentResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return attachmentResponse{}, err
+	}
+
+	return response, nil
+}
+
+// downloadAttachment возвращает содержимое вложения и его Content-Type
+func (tc *testClient) downloadAttachment(userID int64, adID int64, attachmentID int64) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/attachments/%d", adID, attachmentID), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("unexpected error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code: %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read response: %w", err)
+	}
+
+	return content, resp.Header.Get("Content-Type"), nil
+}
+
+func (tc *testClient) deleteAttachment(userID int64, adID int64, attachmentID int64) (adResponse, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/attachments/%d", adID, attachmentID), nil)
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) deleteAd(userID int64, adID int64) (adResponse, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), nil)
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
 
 	var response adResponse
 	err = tc.getResponse(req, &response)
@@ -161,8 +574,328 @@ func (tc *testClient) updateAd(userID int64, adID int64, title string, text stri
 	return response, nil
 }
 
+func (tc *testClient) restoreAd(userID int64, adID int64) (adResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d/restore", adID), nil)
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+// createUser регистрирует пользователя с паролем testPassword и запоминает его токен доступа
+func (tc *testClient) createUser(nickname string, email string) (userResponse, error) {
+	response, err := tc.createUserWithPassword(nickname, email, testPassword)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	token, err := tc.login(response.Data.ID, testPassword)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	tc.tokens[response.Data.ID] = token.Data.Token
+
+	return response, nil
+}
+
+func (tc *testClient) createUserWithPassword(nickname string, email string, password string) (userResponse, error) {
+	body := map[string]any{
+		"name":     nickname,
+		"email":    email,
+		"password": password,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/users", bytes.NewReader(data))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) login(userID int64, password string) (tokenResponse, error) {
+	return tc.postLogin(map[string]any{"user_id": userID, "password": password})
+}
+
+func (tc *testClient) loginByEmail(email string, password string) (tokenResponse, error) {
+	return tc.postLogin(map[string]any{"email": email, "password": password})
+}
+
+func (tc *testClient) postLogin(body map[string]any) (tokenResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/sessions", bytes.NewReader(data))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response tokenResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) updateUser(userID int64, nickname string, email string) (userResponse, error) {
+	return tc.updateUserAs(userID, userID, nickname, email)
+}
+
+// updateUserAs изменяет пользователя userID от имени пользователя actorID
+func (tc *testClient) updateUserAs(actorID int64, userID int64, nickname string, email string) (userResponse, error) {
+	body := map[string]any{
+		"name":        nickname,
+		"email":       email,
+		"update_mask": "name,email",
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), bytes.NewReader(data))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	tc.authorize(req, actorID)
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+// patchAd отправляет JSON Merge Patch объявления как есть, чтобы тесты могли передавать null
+func (tc *testClient) patchAd(userID int64, adID int64, patch string) (adResponse, error) {
+	req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf(tc.baseURL+"/api/v1/ads/%d", adID), strings.NewReader(patch))
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/merge-patch+json")
+	tc.authorize(req, userID)
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+// patchUser отправляет JSON Merge Patch пользователя userID от имени пользователя actorID
+func (tc *testClient) patchUser(actorID int64, userID int64, patch string) (userResponse, error) {
+	req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), strings.NewReader(patch))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/merge-patch+json")
+	tc.authorize(req, actorID)
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+// setUserRole назначает роль пользователю userID от имени пользователя actorID
+func (tc *testClient) setUserRole(actorID int64, userID int64, role string) (userResponse, error) {
+	data, err := json.Marshal(map[string]any{"role": role})
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d/role", userID), bytes.NewReader(data))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	tc.authorize(req, actorID)
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) getUserByID(userID int64) (userResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), nil)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) deleteUser(userID int64) (userResponse, error) {
+	return tc.deleteUserAs(userID, userID)
+}
+
+// deleteUserAs удаляет пользователя userID от имени пользователя actorID
+func (tc *testClient) deleteUserAs(actorID int64, userID int64) (userResponse, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), nil)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, actorID)
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) searchAdByName(title string) (adResponse, error) {
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/ads/search/%s", title), nil)
+
+	if err != nil {
+		return adResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response adResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) filterAds(options ...app.FilterOption) (adsResponse, error) {
+	return tc.filterAdsAs(-1, options...)
+}
+
+// filterAdsAs запрашивает список от имени пользователя userID: неопубликованные объявления
+// отбираются только для их автора и модераторов
+func (tc *testClient) filterAdsAs(userID int64, options ...app.FilterOption) (adsResponse, error) {
+	filter := app.NewFilter(options...)
+	query := url.Values{}
+	if filter.AuthorID != -1 {
+		query.Set("author_id", strconv.FormatInt(filter.AuthorID, 10))
+	}
+	if !filter.PublishedAfter.IsZero() {
+		query.Set("published_after", filter.PublishedAfter.Format(time.RFC3339Nano))
+	}
+	if !filter.PublishedBefore.IsZero() {
+		query.Set("published_before", filter.PublishedBefore.Format(time.RFC3339Nano))
+	}
+	query.Set("status", string(filter.Status))
+	if filter.TitleContains != "" {
+		query.Set("title", filter.TitleContains)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		query.Set("updated_after", filter.UpdatedAfter.Format(time.RFC3339Nano))
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		query.Set("updated_before", filter.UpdatedBefore.Format(time.RFC3339Nano))
+	}
+	query.Set("sort", string(filter.SortBy))
+	if filter.Descending {
+		query.Set("descending", "true")
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Cursor != "" {
+		query.Set("cursor", filter.Cursor)
+	}
+	if filter.MinPrice != -1 {
+		query.Set("min_price", strconv.FormatInt(filter.MinPrice, 10))
+	}
+	if filter.MaxPrice != -1 {
+		query.Set("max_price", strconv.FormatInt(filter.MaxPrice, 10))
+	}
+	for param, value := range map[string]string{"currency": filter.Currency, "category": filter.Category, "region": filter.Region, "city": filter.City} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	return tc.filterAdsQueryAs(userID, query)
+}
+
 func (tc *testClient) listAds() (adsResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads", nil)
+	return tc.filterAdsQuery(url.Values{})
+}
+
+func (tc *testClient) filterAdsQuery(query url.Values) (adsResponse, error) {
+	return tc.filterAdsQueryAs(-1, query)
+}
+
+// filterAdsQueryAs запрашивает список от имени пользователя userID
+func (tc *testClient) filterAdsQueryAs(userID int64, query url.Values) (adsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads?"+query.Encode(), nil)
+	if err != nil {
+		return adsResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	tc.authorize(req, userID)
+
+	var response adsResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return adsResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) searchAds(query string) (adsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads/search?"+url.Values{"query": {query}}.Encode(), nil)
 	if err != nil {
 		return adsResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
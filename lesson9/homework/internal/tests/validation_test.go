@@ -1,6 +1,9 @@
 package tests
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -10,75 +13,129 @@ import (
 func TestCreateAd_EmptyTitle(t *testing.T) {
 	client := getTestClient()
 
-	_, err := client.createAd(123, "", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	_, err := client.createAd(0, "", "world")
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestCreateAd_TooLongTitle(t *testing.T) {
 	client := getTestClient()
 
+	_, _ = client.createUser("Bob", "bob@box.com")
+
 	title := strings.Repeat("a", 101)
 
-	_, err := client.createAd(123, title, "world")
+	_, err := client.createAd(0, title, "world")
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestCreateAd_EmptyText(t *testing.T) {
 	client := getTestClient()
 
-	_, err := client.createAd(123, "title", "")
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	_, err := client.createAd(0, "title", "")
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestCreateAd_TooLongText(t *testing.T) {
 	client := getTestClient()
 
+	_, _ = client.createUser("Bob", "bob@box.com")
+
 	text := strings.Repeat("a", 501)
 
-	_, err := client.createAd(123, "title", text)
+	_, err := client.createAd(0, "title", text)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_EmptyTitle(t *testing.T) {
 	client := getTestClient()
 
-	resp, err := client.createAd(123, "hello", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(123, resp.Data.ID, "", "new_world")
+	_, err = client.updateAd(0, resp.Data.ID, "", "new_world")
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_TooLongTitle(t *testing.T) {
 	client := getTestClient()
 
-	resp, err := client.createAd(123, "hello", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 
 	title := strings.Repeat("a", 101)
 
-	_, err = client.updateAd(123, resp.Data.ID, title, "world")
+	_, err = client.updateAd(0, resp.Data.ID, title, "world")
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_EmptyText(t *testing.T) {
 	client := getTestClient()
 
-	resp, err := client.createAd(123, "hello", "world")
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(123, resp.Data.ID, "title", "")
+	_, err = client.updateAd(0, resp.Data.ID, "title", "")
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
 
 func TestUpdateAd_TooLongText(t *testing.T) {
 	client := getTestClient()
 
+	_, _ = client.createUser("Bob", "bob@box.com")
+
 	text := strings.Repeat("a", 501)
 
-	resp, err := client.createAd(123, "hello", "world")
+	resp, err := client.createAd(0, "hello", "world")
 	assert.NoError(t, err)
 
-	_, err = client.updateAd(123, resp.Data.ID, "title", text)
+	_, err = client.updateAd(0, resp.Data.ID, "title", text)
 	assert.ErrorIs(t, err, ErrBadRequest)
 }
+
+func TestUpdateAd_InvalidIsNotPersisted(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	resp, err := client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.updateAd(0, resp.Data.ID, strings.Repeat("a", 101), "new_world")
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	gotAd, err := client.getAdByIDAs(0, resp.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, gotAd.Data.Title, "hello")
+	assert.Equal(t, gotAd.Data.Text, "world")
+	assert.True(t, gotAd.Data.UpdateDate.IsZero())
+}
+
+func TestFilterAds_InvalidQuery(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.filterAdsQuery(url.Values{"author_id": {"bob"}})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	resp, err := client.client.Get(client.baseURL + "/api/v1/ads?published_after=yesterday&limit=10")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var body struct {
+		Data  json.RawMessage `json:"data"`
+		Error string          `json:"error"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "null", string(body.Data))
+	assert.Contains(t, body.Error, "published_after")
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+func TestAdVersion(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), ad.Data.Version)
+
+	response, err := client.updateAd(0, ad.Data.ID, "hello", "everyone")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), response.Data.Version)
+
+	// переход между состояниями тоже меняет версию
+	response, err = client.transitionAd(0, ad.Data.ID, "pending", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), response.Data.Version)
+
+	etag, err := client.getAdETag(0, ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, `"3"`, etag)
+}
+
+func TestUpdateAd_IfMatch(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	etag, err := client.getAdETag(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	// первая вкладка сохраняет изменения
+	response, err := client.updateAdIfMatch(0, ad.Data.ID, "first", "tab", etag)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", response.Data.Title)
+
+	// вторая вкладка опирается на устаревшую версию и не затирает их
+	_, err = client.updateAdIfMatch(0, ad.Data.ID, "second", "tab", etag)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	current, err := client.getAdByIDAs(0, ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", current.Data.Title)
+
+	etag, err = client.getAdETag(0, ad.Data.ID)
+	require.NoError(t, err)
+
+	response, err = client.updateAdIfMatch(0, ad.Data.ID, "second", "tab", etag)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", response.Data.Title)
+
+	_, err = client.updateAdIfMatch(0, ad.Data.ID, "any", "version", "*")
+	assert.NoError(t, err)
+}
+
+func TestUpdateAd_IfMatchNotValid(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	// слабый ETag не проходит сильное сравнение
+	_, err = client.updateAdIfMatch(0, ad.Data.ID, "hello", "world", `W/"1"`)
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = client.updateAdIfMatch(0, ad.Data.ID, "hello", "world", "1")
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, err = client.updateAdIfMatch(0, ad.Data.ID, "hello", "world", `"1", "2"`)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	// устаревшая версия не скрывает отсутствие объявления
+	_, err = client.updateAdIfMatch(0, 100, "hello", "world", `"1"`)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGRPCUpdateAd_ExpectedVersion(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	user, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Oleg", Email: "oleg@box.com", Password: testPassword})
+	require.NoError(t, err)
+	ctx = loginGRPC(t, ctx, client, user.Id)
+
+	ad, err := client.CreateAd(ctx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), ad.Version)
+
+	res, err := client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "first", Text: "tab", ExpectedVersion: ad.Version})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.Version)
+
+	_, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "second", Text: "tab", ExpectedVersion: ad.Version})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	got, err := client.GetAd(ctx, &grpcPort.GetAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, "first", got.Title)
+
+	// без ожидаемой версии изменение применяется к текущей
+	res, err = client.UpdateAd(ctx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "second", Text: "tab"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), res.Version)
+}
+
+func TestUpdateAd_ConcurrentIfMatch(t *testing.T) {
+	client := getTestClient()
+
+	_, _ = client.createUser("Bob", "bob@box.com")
+
+	ad, err := client.createAd(0, "hello", "world")
+	require.NoError(t, err)
+
+	const writers = 8
+	results := make(chan error, writers)
+
+	for i := 0; i < writers; i++ {
+		go func() {
+			_, err := client.updateAdIfMatch(0, ad.Data.ID, "hello", "world", `"1"`)
+			results <- err
+		}()
+	}
+
+	// из параллельных изменений одной версии проходит ровно одно
+	succeeded := 0
+	for i := 0; i < writers; i++ {
+		err := <-results
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrPreconditionFailed)
+	}
+	assert.Equal(t, 1, succeeded)
+}
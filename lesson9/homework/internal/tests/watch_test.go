@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"homework9/internal/adapters/adrepo"
+	"homework9/internal/ads"
+	"homework9/internal/events"
+	grpcPort "homework9/internal/ports/grpc"
+)
+
+// watchAds подписывается и дожидается заголовков, после которых события уже не теряются
+func watchAds(t *testing.T, ctx context.Context, client grpcPort.AdServiceClient, req *grpcPort.WatchAdsRequest) grpcPort.AdService_WatchAdsClient {
+	stream, err := client.WatchAds(ctx, req)
+	require.NoError(t, err)
+
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	return stream
+}
+
+func recvEvent(t *testing.T, stream grpcPort.AdService_WatchAdsClient) *grpcPort.AdEvent {
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	return event
+}
+
+func TestGRPCWatchPublishedAds(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	stream := watchAds(t, ctx, client, &grpcPort.WatchAdsRequest{})
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	// отправка на проверку не затрагивает опубликованные объявления, и события о ней не приходит
+	publishGRPC(t, client, bobCtx, modCtx, ad.Id)
+
+	_, err = client.UpdateAd(bobCtx, &grpcPort.UpdateAdRequest{AdId: ad.Id, Title: "привет", Text: "мир"})
+	assert.NoError(t, err)
+
+	_, err = client.TransitionAd(bobCtx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "archived"})
+	assert.NoError(t, err)
+
+	// снятое с публикации объявление больше не подходит под фильтр ни до, ни после удаления
+	_, err = client.DeleteAd(bobCtx, &grpcPort.DeleteAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+
+	other, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "best cat", Text: "not for sale"})
+	assert.NoError(t, err)
+
+	publishGRPC(t, client, bobCtx, modCtx, other.Id)
+
+	event := recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_PUBLISHED, event.Kind)
+	assert.Equal(t, ad.Id, event.Ad.Id)
+	assert.True(t, event.Ad.Published)
+
+	event = recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_UPDATED, event.Kind)
+	assert.Equal(t, "привет", event.Ad.Title)
+
+	// снятое с публикации объявление подписчику уже недоступно, и он узнает только его ID
+	event = recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_UNPUBLISHED, event.Kind)
+	assert.Equal(t, ad.Id, event.Ad.Id)
+	assert.False(t, event.Ad.Published)
+	assert.Empty(t, event.Ad.Title)
+	assert.Empty(t, event.Ad.State)
+
+	event = recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_PUBLISHED, event.Kind)
+	assert.Equal(t, other.Id, event.Ad.Id)
+}
+
+func TestGRPCWatchRejectedAdHidesContent(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	publishGRPC(t, client, bobCtx, modCtx, ad.Id)
+
+	stream := watchAds(t, ctx, client, &grpcPort.WatchAdsRequest{})
+
+	_, err = client.TransitionAd(modCtx, &grpcPort.TransitionAdRequest{AdId: ad.Id, State: "rejected", Reason: "spam"})
+	assert.NoError(t, err)
+
+	event := recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_UNPUBLISHED, event.Kind)
+	assert.Equal(t, ad.Id, event.Ad.Id)
+	assert.Empty(t, event.Ad.State)
+	assert.Empty(t, event.Ad.RejectionReason)
+	assert.Empty(t, event.Ad.Title)
+	assert.Empty(t, event.Ad.Text)
+}
+
+func TestGRPCWatchAuthorAds(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	dob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob@box.com", Password: testPassword})
+	assert.NoError(t, err)
+	dobCtx := loginGRPC(t, ctx, client, dob.Id)
+
+	stream := watchAds(t, dobCtx, client, &grpcPort.WatchAdsRequest{AuthorId: &dob.Id, Status: "all"})
+
+	_, err = client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	ad, err := client.CreateAd(dobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteAd(dobCtx, &grpcPort.DeleteAdRequest{AdId: ad.Id})
+	assert.NoError(t, err)
+
+	second, err := client.CreateAd(dobCtx, &grpcPort.CreateAdRequest{Title: "best cat", Text: "not for sale"})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteUser(dobCtx, &grpcPort.DeleteUserRequest{Id: dob.Id})
+	assert.NoError(t, err)
+
+	event := recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_CREATED, event.Kind)
+	assert.Equal(t, ad.Id, event.Ad.Id)
+	assert.Equal(t, dob.Id, event.Ad.AuthorId)
+
+	event = recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_DELETED, event.Kind)
+	assert.Equal(t, ad.Id, event.Ad.Id)
+	assert.NotNil(t, event.Ad.DeletedAt)
+
+	event = recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_CREATED, event.Kind)
+	assert.Equal(t, second.Id, event.Ad.Id)
+
+	// удаление пользователя оповещает об удалении его объявлений
+	event = recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_DELETED, event.Kind)
+	assert.Equal(t, second.Id, event.Ad.Id)
+}
+
+func TestGRPCWatchAdsInvalidStatus(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	stream, err := client.WatchAds(ctx, &grpcPort.WatchAdsRequest{Status: "sold"})
+	assert.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCWatchAdsCancel(t *testing.T) {
+	client, ctx := getGRPCClient(t)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream := watchAds(t, watchCtx, client, &grpcPort.WatchAdsRequest{})
+
+	cancel()
+
+	_, err := stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestEventBusDropsLaggingSubscriber(t *testing.T) {
+	bus := events.NewBus()
+
+	slow := bus.Subscribe(nil)
+	filtered := bus.Subscribe(func(e events.Event) (events.Event, bool) {
+		return e, e.Ad.ID == 0
+	})
+
+	for i := 0; i <= events.DefaultBuffer; i++ {
+		bus.Publish(events.Event{Kind: events.AdCreated, Ad: ads.Ad{ID: int64(i)}})
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(t, events.DefaultBuffer, received)
+	assert.ErrorIs(t, slow.Err(), events.ErrLagged)
+
+	event := <-filtered.Events()
+	assert.Equal(t, int64(0), event.Ad.ID)
+	assert.NoError(t, filtered.Err())
+
+	filtered.Close()
+	filtered.Close()
+
+	_, ok := <-filtered.Events()
+	assert.False(t, ok)
+}
+
+func TestGRPCWatchUnpublishedAds(t *testing.T) {
+	repo := adrepo.New()
+	client, ctx := getGRPCClientWithApp(t, newTestApp(repo))
+
+	bob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Bob", Email: "bob@box.com", Password: testPassword})
+	require.NoError(t, err)
+	bobCtx := loginGRPC(t, ctx, client, bob.Id)
+
+	dob, err := client.CreateUser(ctx, &grpcPort.CreateUserRequest{Name: "Dob", Email: "dob@box.com", Password: testPassword})
+	require.NoError(t, err)
+	dobCtx := loginGRPC(t, ctx, client, dob.Id)
+	modCtx := moderatorGRPC(t, ctx, client, repo)
+
+	watchErr := func(ctx context.Context, req *grpcPort.WatchAdsRequest) codes.Code {
+		stream, err := client.WatchAds(ctx, req)
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		return status.Code(err)
+	}
+
+	// чужие черновики не видны ни анонимно, ни другим пользователям
+	assert.Equal(t, codes.Unauthenticated, watchErr(ctx, &grpcPort.WatchAdsRequest{AuthorId: &bob.Id, Status: "draft"}))
+	assert.Equal(t, codes.PermissionDenied, watchErr(dobCtx, &grpcPort.WatchAdsRequest{AuthorId: &bob.Id, Status: "draft"}))
+	assert.Equal(t, codes.PermissionDenied, watchErr(bobCtx, &grpcPort.WatchAdsRequest{Status: "all"}))
+
+	stream := watchAds(t, modCtx, client, &grpcPort.WatchAdsRequest{Status: "draft"})
+
+	ad, err := client.CreateAd(bobCtx, &grpcPort.CreateAdRequest{Title: "hello", Text: "world"})
+	require.NoError(t, err)
+
+	event := recvEvent(t, stream)
+	assert.Equal(t, grpcPort.AdEvent_CREATED, event.Kind)
+	assert.Equal(t, ad.Id, event.Ad.Id)
+}
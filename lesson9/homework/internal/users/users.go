@@ -0,0 +1,29 @@
+package users
+
+// Role определяет, что пользователь может делать с чужими объявлениями и учетными записями
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+type User struct {
+	ID       int64
+	Nickname string `validate:"max:32"`
+	// Email проверяется приложением: у validator нет правила для адресов.
+	// Адрес уникален без учета регистра
+	Email string
+	// PasswordHash - bcrypt-хеш пароля, сам пароль не хранится
+	PasswordHash string
+	Role         Role
+}
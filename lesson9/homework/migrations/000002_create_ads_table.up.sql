@@ -0,0 +1,12 @@
+CREATE TABLE ads (
+    id bigint primary key,
+    title text not null,
+    text text not null,
+    author_id bigint not null references users (id),
+    published boolean not null default false,
+    creation_date timestamptz not null,
+    update_date timestamptz
+);
+
+CREATE INDEX ads_author_id_idx ON ads (author_id);
+CREATE INDEX ads_creation_date_idx ON ads (creation_date);
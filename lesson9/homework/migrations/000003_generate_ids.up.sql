@@ -0,0 +1,5 @@
+ALTER TABLE users ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY (START WITH 0 MINVALUE 0);
+SELECT setval(pg_get_serial_sequence('users', 'id'), max(id)) FROM users;
+
+ALTER TABLE ads ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY (START WITH 0 MINVALUE 0);
+SELECT setval(pg_get_serial_sequence('ads', 'id'), max(id)) FROM ads;
@@ -0,0 +1,2 @@
+DROP INDEX ads_deleted_at_idx;
+ALTER TABLE ads DROP COLUMN deleted_at;
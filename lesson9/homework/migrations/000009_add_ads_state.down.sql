@@ -0,0 +1,8 @@
+DROP TABLE ad_transitions;
+
+ALTER TABLE ads ADD COLUMN published boolean not null default false;
+UPDATE ads SET published = state = 'published';
+
+DROP INDEX ads_state_idx;
+ALTER TABLE ads DROP COLUMN rejection_reason;
+ALTER TABLE ads DROP COLUMN state;
@@ -0,0 +1,18 @@
+ALTER TABLE ads ADD COLUMN state text not null default 'draft';
+ALTER TABLE ads ADD COLUMN rejection_reason text not null default '';
+UPDATE ads SET state = 'published' WHERE published;
+ALTER TABLE ads DROP COLUMN published;
+
+CREATE INDEX ads_state_idx ON ads (state);
+
+CREATE TABLE ad_transitions (
+    id bigserial primary key,
+    ad_id bigint not null references ads (id) on delete cascade,
+    from_state text not null,
+    to_state text not null,
+    actor_id bigint not null,
+    reason text not null default '',
+    created_at timestamptz not null
+);
+
+CREATE INDEX ad_transitions_ad_id_idx ON ad_transitions (ad_id);
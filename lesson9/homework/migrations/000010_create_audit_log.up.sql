@@ -0,0 +1,11 @@
+CREATE TABLE audit_log (
+    id bigserial primary key,
+    actor_id bigint not null,
+    action text not null,
+    resource text not null,
+    resource_id bigint not null,
+    changes jsonb not null default '[]',
+    created_at timestamptz not null
+);
+
+CREATE INDEX audit_log_resource_idx ON audit_log (resource, resource_id, id);
@@ -0,0 +1 @@
+ALTER TABLE ads DROP COLUMN version;
@@ -0,0 +1,11 @@
+CREATE TABLE ad_attachments (
+    id bigint primary key generated by default as identity (start with 0 minvalue 0),
+    ad_id bigint not null references ads (id) on delete cascade,
+    name text not null,
+    content_type text not null,
+    size bigint not null,
+    blob_key text not null unique,
+    created_at timestamptz not null
+);
+
+CREATE INDEX ad_attachments_ad_id_idx ON ad_attachments (ad_id, id);
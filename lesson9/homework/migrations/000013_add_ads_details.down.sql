@@ -0,0 +1,9 @@
+DROP INDEX ads_location_idx;
+DROP INDEX ads_category_idx;
+DROP INDEX ads_price_idx;
+
+ALTER TABLE ads DROP COLUMN city;
+ALTER TABLE ads DROP COLUMN region;
+ALTER TABLE ads DROP COLUMN category;
+ALTER TABLE ads DROP COLUMN currency;
+ALTER TABLE ads DROP COLUMN price;
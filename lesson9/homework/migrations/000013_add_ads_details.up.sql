@@ -0,0 +1,10 @@
+ALTER TABLE ads ADD COLUMN price bigint not null default 0 check (price >= 0);
+ALTER TABLE ads ADD COLUMN currency text not null default 'RUB';
+ALTER TABLE ads ADD COLUMN category text not null default '';
+ALTER TABLE ads ADD COLUMN region text not null default '';
+ALTER TABLE ads ADD COLUMN city text not null default '';
+
+CREATE INDEX ads_price_idx ON ads (currency, price);
+-- text_pattern_ops нужен, чтобы отбор поддерева категорий через LIKE 'path/%' использовал индекс
+CREATE INDEX ads_category_idx ON ads (category text_pattern_ops);
+CREATE INDEX ads_location_idx ON ads (lower(region), lower(city));